@@ -9,6 +9,7 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 	"gopkg.in/guregu/null.v4"
 )
 
@@ -18,10 +19,11 @@ type AccountDetails struct {
 }
 
 type AccountManager struct {
-	accounts    []db.Account
-	inUse       []bool
-	db          db.DbDetails
-	accountLock sync.Mutex
+	accounts       []db.Account
+	inUse          []bool
+	workedSessions map[string]bool // usernames whose current session received map objects
+	db             db.DbDetails
+	accountLock    sync.Mutex
 }
 
 type AccountStatus struct {
@@ -29,6 +31,21 @@ type AccountStatus struct {
 	InUse bool
 }
 
+// AccountUpdate describes the changes of an account, empty strings and false keep the current value
+type AccountUpdate struct {
+	NewUsername    string
+	NewPassword    string
+	ClearBanned    bool
+	ClearWarn      bool
+	ClearDisabled  bool
+	ClearSuspended bool
+	ClearInvalid   bool
+}
+
+var ErrAccountNotFound = errors.New("account not found")
+var ErrAccountInUse = errors.New("account is in use")
+var ErrAccountExists = errors.New("account with new username already exists")
+
 func (a *AccountManager) GetAccountDetails() []AccountStatus {
 	accountStatusList := make([]AccountStatus, 0)
 	for n, account := range a.accounts {
@@ -48,6 +65,7 @@ func (a *AccountManager) LoadAccounts(dbDetails db.DbDetails) {
 		panic(err)
 	}
 	a.inUse = make([]bool, len(a.accounts))
+	a.workedSessions = make(map[string]bool)
 	a.db = dbDetails
 }
 
@@ -99,7 +117,6 @@ func (a *AccountManager) GetNextAccount(testAccount func(a db.Account) bool) *Ac
 	a.accountLock.Lock()
 	defer a.accountLock.Unlock()
 
-	hoursDisabled := []int{24, 7 * 24, 30 * 24}
 	timeNow := time.Now()
 	timeNowUnix := timeNow.Unix()
 	minimumTimeForReuse := timeNow
@@ -113,15 +130,13 @@ func (a *AccountManager) GetNextAccount(testAccount func(a db.Account) bool) *Ac
 	bestAccount := -1
 	for x := 0; x < len(a.accounts); x++ {
 		account := a.accounts[x]
-		disabledTimeout := time.Duration(hoursDisabled[0]) * time.Hour
-		// TODO consecutive count
 
 		if a.inUse[x] ||
 			account.Suspended ||
 			account.Banned ||
 			account.Invalid ||
 			int64(account.WarnExpiration) > timeNowUnix ||
-			(account.LastDisabled.Valid && account.LastDisabled.Int64 > timeNow.Add(-disabledTimeout).Unix()) ||
+			DisabledUntil(account) > timeNowUnix ||
 			(account.LastSelected.Valid && account.LastSelected.Int64 > minimumTimeForReuseUnix) {
 			continue
 		}
@@ -156,6 +171,7 @@ func (a *AccountManager) GetNextAccount(testAccount func(a db.Account) bool) *Ac
 	}
 
 	a.inUse[bestAccount] = true
+	delete(a.workedSessions, account.Username)
 	account.LastReleased = null.NewInt(0, false)
 	account.LastSelected = null.IntFrom(time.Now().Unix())
 	if err := db.MarkSelected(a.db, account.Username); err != nil {
@@ -174,16 +190,84 @@ func (a *AccountManager) ReleaseAccount(username string) {
 
 	for x := range a.accounts {
 		if a.accounts[x].Username == username {
+			account := &a.accounts[x]
+			if a.inUse[x] && account.ConsecutiveDisabled > 0 && a.workedSessions[username] && isCleanSession(*account) {
+				log.Infof("Account %s finished a session without disable, resetting %d consecutive disables", username, account.ConsecutiveDisabled)
+				account.ConsecutiveDisabled = 0
+				if err := db.ResetConsecutiveDisabled(a.db, username); err != nil {
+					log.Errorf("Error resetting consecutive disables of account %s: %s", username, err)
+				}
+			}
 			a.inUse[x] = false
-			a.accounts[x].LastReleased = null.IntFrom(time.Now().Unix())
+			account.LastReleased = null.IntFrom(time.Now().Unix())
 		}
 	}
+	delete(a.workedSessions, username)
 
 	if err := db.MarkReleased(a.db, username); err != nil {
 		log.Errorf("Error marking account %s as released: %s", username, err)
 	}
 }
 
+// MarkSessionWorked records that the current session of the account received map objects. Only sessions which
+// did work reset the consecutive disables on release
+func (a *AccountManager) MarkSessionWorked(username string) {
+	a.accountLock.Lock()
+	defer a.accountLock.Unlock()
+
+	a.workedSessions[username] = true
+}
+
+// isCleanSession returns true if the account was not disabled, suspended, banned or found invalid since it was selected
+func isCleanSession(account db.Account) bool {
+	return account.LastSelected.Valid &&
+		account.LastDisabled.ValueOrZero() < account.LastSelected.Int64 &&
+		!account.Suspended && !account.Banned && !account.Invalid
+}
+
+// DisabledUntil returns the unix time until a disabled account can't be used. Every consecutive disable
+// moves to the next tier of tuning.disable_hours, the last tier is used for all further disables
+func DisabledUntil(account db.Account) int64 {
+	disableHours := config.Config.Tuning.DisableHours
+	if !account.LastDisabled.Valid || len(disableHours) == 0 {
+		return 0
+	}
+
+	tier := account.ConsecutiveDisabled - 1
+	if tier < 0 {
+		tier = 0
+	}
+	if tier >= len(disableHours) {
+		tier = len(disableHours) - 1
+	}
+	return account.LastDisabled.Int64 + int64(disableHours[tier])*int64(time.Hour/time.Second)
+}
+
+// ReserveAccount marks an account as in use again, used to hand restored workers their previous account.
+// Returns false if the account is unknown, already in use or can not be used anymore
+func (a *AccountManager) ReserveAccount(username string) bool {
+	a.accountLock.Lock()
+	defer a.accountLock.Unlock()
+
+	for x := range a.accounts {
+		if a.accounts[x].Username == username {
+			account := &a.accounts[x]
+			if a.inUse[x] || account.Suspended || account.Banned || account.Invalid {
+				return false
+			}
+
+			a.inUse[x] = true
+			delete(a.workedSessions, username)
+			account.LastReleased = null.NewInt(0, false)
+			if err := db.MarkReserved(a.db, username); err != nil {
+				log.Errorf("Error marking account %s as reserved: %s", username, err)
+			}
+			return true
+		}
+	}
+	return false
+}
+
 func (a *AccountManager) GetAccount(username string) *AccountDetails {
 	for x := range a.accounts {
 		if a.accounts[x].Username == username {
@@ -200,6 +284,154 @@ func (a *AccountManager) GetAccount(username string) *AccountDetails {
 	return nil
 }
 
+// FindAccounts returns the usernames of all accounts passing the test
+func (a *AccountManager) FindAccounts(testAccount func(a db.Account) bool) []string {
+	a.accountLock.Lock()
+	defer a.accountLock.Unlock()
+
+	usernames := []string{}
+	for x := range a.accounts {
+		if testAccount(a.accounts[x]) {
+			usernames = append(usernames, a.accounts[x].Username)
+		}
+	}
+	return usernames
+}
+
+// DeleteAccounts removes the accounts from the database and the manager. Accounts in use are kept,
+// their usernames are returned
+func (a *AccountManager) DeleteAccounts(usernames []string) (int64, []string, error) {
+	a.accountLock.Lock()
+	defer a.accountLock.Unlock()
+
+	requested := usernameSet(usernames)
+	inUse := []string{}
+	toDelete := []string{}
+	for x := range a.accounts {
+		if _, found := requested[a.accounts[x].Username]; !found {
+			continue
+		}
+		if a.inUse[x] {
+			inUse = append(inUse, a.accounts[x].Username)
+		} else {
+			toDelete = append(toDelete, a.accounts[x].Username)
+		}
+	}
+
+	deleted, err := db.DeleteAccounts(a.db, toDelete)
+	if err != nil {
+		return 0, inUse, err
+	}
+
+	deletedSet := usernameSet(toDelete)
+	keptAccounts := a.accounts[:0]
+	keptInUse := a.inUse[:0]
+	for x := range a.accounts {
+		if _, found := deletedSet[a.accounts[x].Username]; !found {
+			keptAccounts = append(keptAccounts, a.accounts[x])
+			keptInUse = append(keptInUse, a.inUse[x])
+		}
+	}
+	a.accounts = keptAccounts
+	a.inUse = keptInUse
+	log.Infof("Deleted %d accounts", deleted)
+
+	return deleted, inUse, nil
+}
+
+// UpdateAccount changes the credentials of an account and resets its status. Accounts in use can't be renamed
+func (a *AccountManager) UpdateAccount(username string, update AccountUpdate) error {
+	a.accountLock.Lock()
+	defer a.accountLock.Unlock()
+
+	index := slices.IndexFunc(a.accounts, func(account db.Account) bool {
+		return account.Username == username
+	})
+	if index == -1 {
+		return ErrAccountNotFound
+	}
+
+	account := a.accounts[index]
+	if update.NewUsername != "" && update.NewUsername != username {
+		if a.inUse[index] {
+			return ErrAccountInUse
+		}
+		if slices.ContainsFunc(a.accounts, func(other db.Account) bool { return other.Username == update.NewUsername }) {
+			return ErrAccountExists
+		}
+		account.Username = update.NewUsername
+	}
+	if update.NewPassword != "" {
+		account.Password = update.NewPassword
+	}
+	if update.ClearBanned {
+		account.Banned = false
+	}
+	if update.ClearWarn {
+		account.Warn = false
+		account.WarnExpiration = 0
+	}
+	if update.ClearDisabled {
+		account.LastDisabled = null.NewInt(0, false)
+		account.ConsecutiveDisabled = 0
+	}
+	if update.ClearSuspended {
+		account.Suspended = false
+	}
+	if update.ClearInvalid {
+		account.Invalid = false
+	}
+
+	if err := db.UpdateAccount(a.db, username, account); err != nil {
+		return err
+	}
+	a.accounts[index] = account
+	log.Infof("Account %s updated", account.Username)
+
+	return nil
+}
+
+// AccountMatches returns true if the account exists and passes the test
+func (a *AccountManager) AccountMatches(username string, testAccount func(a db.Account) bool) bool {
+	a.accountLock.Lock()
+	defer a.accountLock.Unlock()
+
+	for x := range a.accounts {
+		if a.accounts[x].Username == username {
+			return testAccount(a.accounts[x])
+		}
+	}
+	return false
+}
+
+// SetAccountGroup moves the accounts into a group, an empty group removes them from their group
+func (a *AccountManager) SetAccountGroup(usernames []string, group string) (int64, error) {
+	a.accountLock.Lock()
+	defer a.accountLock.Unlock()
+
+	accountGroup := null.NewString(group, group != "")
+	rows, err := db.SetAccountGroup(a.db, usernames, accountGroup)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := usernameSet(usernames)
+	for x := range a.accounts {
+		if _, found := changed[a.accounts[x].Username]; found {
+			a.accounts[x].AccountGroup = accountGroup
+		}
+	}
+	return rows, nil
+}
+
+func usernameSet(usernames []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(usernames))
+	for _, username := range usernames {
+		set[username] = struct{}{}
+	}
+	return set
+}
+
 func (a *AccountManager) AccountExists(username string) bool {
 	for x := range a.accounts {
 		if a.accounts[x].Username == username {
@@ -213,13 +445,11 @@ func (a *AccountManager) AccountExists(username string) bool {
 func (a *AccountManager) IsValidAccount(username string) (bool, error) {
 	for x := range a.accounts {
 		if a.accounts[x].Username == username {
-			timeNow := time.Now()
-			timeNowUnix := timeNow.Unix()
-			time24hrAgo := timeNow.Add(-24 * time.Hour).Unix()
+			timeNowUnix := time.Now().Unix()
 			return !(a.accounts[x].Suspended ||
 				a.accounts[x].Banned ||
 				int64(a.accounts[x].WarnExpiration) > timeNowUnix ||
-				(a.accounts[x].LastDisabled.Valid && a.accounts[x].LastDisabled.Int64 > time24hrAgo)), nil
+				DisabledUntil(a.accounts[x]) > timeNowUnix), nil
 		}
 	}
 	log.Errorf("Account with username '%s' not found in accounts", username)
@@ -278,6 +508,9 @@ func (a *AccountManager) MarkDisabled(username string) {
 	for x := range a.accounts {
 		if a.accounts[x].Username == username {
 			a.accounts[x].LastDisabled = null.IntFrom(time.Now().Unix())
+			a.accounts[x].ConsecutiveDisabled++
+			log.Infof("Account %s disabled for the %d. time in a row, not used until %s", username, a.accounts[x].ConsecutiveDisabled,
+				time.Unix(DisabledUntil(a.accounts[x]), 0).Format(time.DateTime))
 		}
 	}
 	if err := db.MarkDisabled(a.db, username); err != nil {
@@ -364,3 +597,13 @@ func SelectLevel30(account db.Account) bool {
 func SelectUnderLevel30(account db.Account) bool {
 	return account.Level < 30
 }
+
+// SelectGroups returns a filter for accounts of the groups, without groups only accounts without group are selected
+func SelectGroups(groups []string) func(account db.Account) bool {
+	return func(account db.Account) bool {
+		if len(groups) == 0 {
+			return !account.AccountGroup.Valid
+		}
+		return account.AccountGroup.Valid && slices.Contains(groups, account.AccountGroup.String)
+	}
+}
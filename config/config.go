@@ -13,6 +13,7 @@ type configDefinition struct {
 }
 
 type generalDefinition struct {
+	WorkerStats         bool   `koanf:"worker_stats"`
 	WorkerStatsInterval int    `koanf:"worker_stats_interval"`
 	SaveLogs            bool   `koanf:"save_logs"`
 	DebugLogging        bool   `koanf:"debug_log"`
@@ -31,8 +32,20 @@ type processorDefinition struct {
 }
 
 type workerDefinition struct {
-	LoginDelay       int `koanf:"login_delay"`
-	RoutePartTimeout int `koanf:"route_part_timeout"`
+	LoginDelay               int                     `koanf:"login_delay"`
+	RoutePartTimeout         int                     `koanf:"route_part_timeout"`
+	StateExpiry              int                     `koanf:"state_expiry"`
+	JobLeaseTimeout          int                     `koanf:"job_lease_timeout"`
+	EncounterPriorityPokemon []int                   `koanf:"encounter_priority_pokemon"`
+	AccountGroups            accountGroupsDefinition `koanf:"account_groups"`
+}
+
+type accountGroupsDefinition struct {
+	Pokemon   []string `koanf:"pokemon"`
+	Fort      []string `koanf:"fort"`
+	Quest     []string `koanf:"quest"`
+	Leveling  []string `koanf:"leveling"`
+	Encounter []string `koanf:"encounter"`
 }
 
 type DbDefinition struct {
@@ -45,9 +58,10 @@ type DbDefinition struct {
 }
 
 type tuningDefinition struct {
-	RecycleGmoLimit          int `koanf:"recycle_gmo_limit"`
-	RecycleEncounterLimit    int `koanf:"recycle_encounter_limit"`
-	MinimumAccountReuseHours int `koanf:"minimum_account_reuse_hours"`
+	RecycleGmoLimit          int   `koanf:"recycle_gmo_limit"`
+	RecycleEncounterLimit    int   `koanf:"recycle_encounter_limit"`
+	MinimumAccountReuseHours int   `koanf:"minimum_account_reuse_hours"`
+	DisableHours             []int `koanf:"disable_hours"`
 }
 
 type sentry struct {
@@ -12,6 +12,9 @@ import (
 
 var k = koanf.New(".")
 
+// defaultDisableHours are the disable tiers used when tuning.disable_hours is not set or empty
+var defaultDisableHours = []int{24, 7 * 24, 30 * 24}
+
 func ReadConfig() {
 	// load default values
 	defaultErr := k.Load(structs.Provider(configDefinition{
@@ -23,8 +26,13 @@ func ReadConfig() {
 		},
 		Worker: workerDefinition{
 			RoutePartTimeout: 150,
+			StateExpiry:      3600,
+			JobLeaseTimeout:  60,
 			LoginDelay:       20,
 		},
+		Tuning: tuningDefinition{
+			DisableHours: defaultDisableHours,
+		},
 		Sentry: sentry{
 			SampleRate:       1.0,
 			TracesSampleRate: 1.0,
@@ -62,6 +70,11 @@ func ReadConfig() {
 		panic(fmt.Errorf("failed to Unmarshal config: %w", unmarshalError))
 		return
 	}
+
+	if len(Config.Tuning.DisableHours) == 0 {
+		fmt.Println("tuning.disable_hours needs at least one tier, using the default tiers")
+		Config.Tuning.DisableHours = defaultDisableHours
+	}
 }
 
 func parseEnvVarToSlice(sliceName string, key string, value string, currentMap map[string]interface{}) {
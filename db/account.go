@@ -3,23 +3,27 @@ package db
 import (
 	"database/sql"
 	"flygon/pogo"
+	"fmt"
+	"github.com/jmoiron/sqlx"
 	"gopkg.in/guregu/null.v4"
 )
 
 type Account struct {
-	Username       string   `db:"username"`
-	Password       string   `db:"password"`
-	Level          int      `db:"level"`
-	Warn           bool     `db:"warn"`
-	WarnExpiration int      `db:"warn_expiration"`
-	Suspended      bool     `db:"suspended"`
-	LastSuspended  null.Int `db:"last_suspended"`
-	Banned         bool     `db:"banned"`
-	LastBanned     null.Int `db:"last_banned"`
-	LastDisabled   null.Int `db:"last_disabled"`
-	Invalid        bool     `db:"invalid"`
-	LastSelected   null.Int `db:"last_selected"`
-	LastReleased   null.Int `db:"last_released"`
+	Username            string      `db:"username"`
+	Password            string      `db:"password"`
+	Level               int         `db:"level"`
+	AccountGroup        null.String `db:"account_group"`
+	Warn                bool        `db:"warn"`
+	WarnExpiration      int         `db:"warn_expiration"`
+	Suspended           bool        `db:"suspended"`
+	LastSuspended       null.Int    `db:"last_suspended"`
+	Banned              bool        `db:"banned"`
+	LastBanned          null.Int    `db:"last_banned"`
+	LastDisabled        null.Int    `db:"last_disabled"`
+	ConsecutiveDisabled int         `db:"consecutive_disabled"`
+	Invalid             bool        `db:"invalid"`
+	LastSelected        null.Int    `db:"last_selected"`
+	LastReleased        null.Int    `db:"last_released"`
 }
 
 type AccountsStats struct {
@@ -42,9 +46,10 @@ type LevelStats struct {
 }
 
 type NewAccountRow struct {
-	Username string `db:"username"`
-	Password string `db:"password"`
-	Level    int    `db:"level"`
+	Username     string      `db:"username"`
+	Password     string      `db:"password"`
+	Level        int         `db:"level"`
+	AccountGroup null.String `db:"account_group"`
 }
 
 func GetAccountRecords(db DbDetails) ([]Account, error) {
@@ -62,9 +67,24 @@ func GetAccountRecords(db DbDetails) ([]Account, error) {
 	return accounts, nil
 }
 
-func GetAccountsStats(db DbDetails) (*AccountsStats, error) {
+// disabledUntilSql returns the SQL expression of the time until an account is disabled, the cooldown
+// of each consecutive disable is taken from the hour tiers
+func disabledUntilSql(disableHours []int) string {
+	if len(disableHours) == 0 {
+		// matches accounts.DisabledUntil, without tiers an account is never disabled
+		return "0"
+	}
+	expression := "last_disabled + CASE"
+	for x, hours := range disableHours[:len(disableHours)-1] {
+		expression += fmt.Sprintf(" WHEN consecutive_disabled <= %d THEN %d", x+1, hours*3600)
+	}
+	return expression + fmt.Sprintf(" ELSE %d END", disableHours[len(disableHours)-1]*3600)
+}
+
+func GetAccountsStats(db DbDetails, disableHours []int) (*AccountsStats, error) {
 	stats := AccountsStats{}
-	err := db.FlygonDb.Get(&stats, "SELECT COUNT(*) AS total, SUM(banned) AS banned, SUM(invalid) AS invalid, SUM(suspended) AS suspended, SUM(warn) AS warned, SUM(CASE WHEN last_disabled > UNIX_TIMESTAMP()-86400 THEN 1 ELSE 0 END) AS disabled FROM account")
+	err := db.FlygonDb.Get(&stats, "SELECT COUNT(*) AS total, SUM(banned) AS banned, SUM(invalid) AS invalid, SUM(suspended) AS suspended, SUM(warn) AS warned, "+
+		"SUM(CASE WHEN "+disabledUntilSql(disableHours)+" > UNIX_TIMESTAMP() THEN 1 ELSE 0 END) AS disabled FROM account")
 
 	if err != nil {
 		return nil, err
@@ -73,7 +93,7 @@ func GetAccountsStats(db DbDetails) (*AccountsStats, error) {
 	return &stats, nil
 }
 
-func GetLevelStats(db DbDetails) ([]LevelStats, error) {
+func GetLevelStats(db DbDetails, disableHours []int) ([]LevelStats, error) {
 	stats := []LevelStats{}
 	err := db.FlygonDb.Select(&stats, "SELECT "+
 		"level, "+
@@ -81,7 +101,7 @@ func GetLevelStats(db DbDetails) ([]LevelStats, error) {
 		"COUNT(IF(suspended = 1, 1, NULL)) AS suspended, "+
 		"COUNT(IF(banned = 1, 1, NULL)) AS banned, "+
 		"COUNT(IF(invalid = 1, 1, NULL)) AS invalid, "+
-		"COUNT(IF(UNIX_TIMESTAMP() - 86400 < last_disabled, 1, NULL)) AS disabled, "+
+		"COUNT(IF(UNIX_TIMESTAMP() < "+disabledUntilSql(disableHours)+", 1, NULL)) AS disabled, "+
 		"COUNT(*) AS count "+
 		"FROM account GROUP BY level ORDER BY level ASC",
 	)
@@ -93,26 +113,109 @@ func GetLevelStats(db DbDetails) ([]LevelStats, error) {
 
 // InsertAccount handles single account addition and returns row ID when new row is created
 func InsertAccount(db DbDetails, account NewAccountRow) (int64, error) {
-	res, err := db.FlygonDb.Exec("INSERT INTO account (username, password, level, last_released) VALUES (?, ?, ?, UNIX_TIMESTAMP())",
-		account.Username, account.Password, account.Level)
+	res, err := db.FlygonDb.Exec("INSERT INTO account (username, password, level, account_group, last_released) VALUES (?, ?, ?, ?, UNIX_TIMESTAMP())",
+		account.Username, account.Password, account.Level, account.AccountGroup)
 	if err != nil {
 		return 0, err
 	}
 	return res.LastInsertId()
 }
 
+// insertAccountsChunkSize is the number of accounts inserted or deleted per statement, which keeps the
+// placeholders below the MySQL limit of 65535
+const insertAccountsChunkSize = 1000
+
 // InsertBulkAccounts handles addition of multiple accounts and returns total number of unique inserted rows
 func InsertBulkAccounts(db DbDetails, accounts []NewAccountRow) (int64, error) {
-	res, err := db.FlygonDb.NamedExec(
-		`INSERT IGNORE INTO account (username, password, level, last_released)
-        VALUES (:username, :password, :level, UNIX_TIMESTAMP())
-    `, accounts)
+	var inserted int64
+	for start := 0; start < len(accounts); start += insertAccountsChunkSize {
+		end := start + insertAccountsChunkSize
+		if end > len(accounts) {
+			end = len(accounts)
+		}
+		res, err := db.FlygonDb.NamedExec(
+			`INSERT IGNORE INTO account (username, password, level, account_group, last_released)
+        VALUES (:username, :password, :level, :account_group, UNIX_TIMESTAMP())
+    `, accounts[start:end])
+		if err != nil {
+			return inserted, err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return inserted, err
+		}
+		inserted += rows
+	}
+	return inserted, nil
+}
+
+// SetAccountGroup moves the accounts into a group, a null group removes them from their group
+func SetAccountGroup(db DbDetails, usernames []string, group null.String) (int64, error) {
+	if len(usernames) == 0 {
+		return 0, nil
+	}
+	query, args, err := sqlx.In("UPDATE account SET account_group = ? WHERE username IN (?)", group, usernames)
+	if err != nil {
+		return 0, err
+	}
+	res, err := db.FlygonDb.Exec(query, args...)
 	if err != nil {
 		return 0, err
 	}
 	return res.RowsAffected()
 }
 
+func DeleteAccounts(db DbDetails, usernames []string) (int64, error) {
+	var deleted int64
+	for start := 0; start < len(usernames); start += insertAccountsChunkSize {
+		end := start + insertAccountsChunkSize
+		if end > len(usernames) {
+			end = len(usernames)
+		}
+		query, args, err := sqlx.In("DELETE FROM account WHERE username IN (?)", usernames[start:end])
+		if err != nil {
+			return deleted, err
+		}
+		res, err := db.FlygonDb.Exec(query, args...)
+		if err != nil {
+			return deleted, err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += rows
+	}
+	return deleted, nil
+}
+
+// UpdateAccount writes the credentials and status of an account, the account is looked up by its previous username
+func UpdateAccount(db DbDetails, username string, account Account) error {
+	_, err := db.FlygonDb.Exec("UPDATE account SET "+
+		"username = ?, "+
+		"password = ?, "+
+		"warn = ?, "+
+		"warn_expiration = ?, "+
+		"suspended = ?, "+
+		"banned = ?, "+
+		"last_disabled = ?, "+
+		"consecutive_disabled = ?, "+
+		"invalid = ? "+
+		"WHERE username = ?",
+		account.Username,
+		account.Password,
+		account.Warn,
+		account.WarnExpiration,
+		account.Suspended,
+		account.Banned,
+		account.LastDisabled,
+		account.ConsecutiveDisabled,
+		account.Invalid,
+		username,
+	)
+	return err
+}
+
 func MarkTutorialDone(db DbDetails, username string) error {
 	_, err := db.FlygonDb.Exec("UPDATE account SET Level=1 WHERE Username=?", username)
 	return err
@@ -124,7 +227,12 @@ func MarkBanned(db DbDetails, username string) error {
 }
 
 func MarkDisabled(db DbDetails, username string) error {
-	_, err := db.FlygonDb.Exec("UPDATE account SET last_disabled=UNIX_TIMESTAMP() WHERE Username=?", username)
+	_, err := db.FlygonDb.Exec("UPDATE account SET last_disabled=UNIX_TIMESTAMP(), consecutive_disabled = consecutive_disabled + 1 WHERE Username=?", username)
+	return err
+}
+
+func ResetConsecutiveDisabled(db DbDetails, username string) error {
+	_, err := db.FlygonDb.Exec("UPDATE account SET consecutive_disabled = 0 WHERE Username=?", username)
 	return err
 }
 
@@ -157,6 +265,12 @@ func MarkReleased(db DbDetails, username string) error {
 
 }
 
+// MarkReserved marks an account as in use without touching last_selected
+func MarkReserved(db DbDetails, username string) error {
+	_, err := db.FlygonDb.Exec("UPDATE account SET last_released = NULL WHERE Username=?", username)
+	return err
+}
+
 func MarkAllReleased(db DbDetails) error {
 	_, err := db.FlygonDb.Exec("UPDATE account SET last_released = UNIX_TIMESTAMP() WHERE last_released IS NULL")
 	return err
@@ -23,11 +23,23 @@ type Area struct {
 	QuestModeRoute     null.String `db:"quest_mode_route"`
 	Geofence           null.String `db:"geofence"`
 	EnableQuests       bool        `db:"enable_quests"`
+	EnableLeveling     bool        `db:"enable_leveling"`
+	Priority           int         `db:"priority"`
+	Weight             int         `db:"weight"`
+	Spillover          bool        `db:"spillover"`
+	RoutePartition     string      `db:"route_partition"`
+	AccountGroups      null.String `db:"account_groups"`
 }
 
+// Route partition strategies of an area
+const (
+	RoutePartitionIndex   = "index"   // contiguous index ranges of the route
+	RoutePartitionCluster = "cluster" // compact geographic clusters
+)
+
 func GetAreaRecords(db DbDetails) ([]Area, error) {
 	areas := []Area{}
-	err := db.FlygonDb.Select(&areas, "SELECT id, name, pokemon_mode_workers, pokemon_mode_route, fort_mode_workers, fort_mode_route, quest_mode_workers, quest_mode_hours, quest_mode_route, geofence, enable_quests FROM area")
+	err := db.FlygonDb.Select(&areas, "SELECT id, name, pokemon_mode_workers, pokemon_mode_route, fort_mode_workers, fort_mode_route, quest_mode_workers, quest_mode_hours, quest_mode_route, geofence, enable_quests, enable_leveling, priority, weight, spillover, route_partition, account_groups FROM area")
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -42,7 +54,7 @@ func GetAreaRecords(db DbDetails) ([]Area, error) {
 
 func GetAreaRecord(db DbDetails, id int) (*Area, error) {
 	area := []Area{}
-	err := db.FlygonDb.Select(&area, "SELECT id, name, pokemon_mode_workers, pokemon_mode_route, fort_mode_workers, fort_mode_route, quest_mode_workers, quest_mode_hours, quest_mode_route, geofence, enable_quests FROM area "+
+	err := db.FlygonDb.Select(&area, "SELECT id, name, pokemon_mode_workers, pokemon_mode_route, fort_mode_workers, fort_mode_route, quest_mode_workers, quest_mode_hours, quest_mode_route, geofence, enable_quests, enable_leveling, priority, weight, spillover, route_partition, account_groups FROM area "+
 		"WHERE id = ?", id)
 
 	if err == sql.ErrNoRows {
@@ -53,12 +65,16 @@ func GetAreaRecord(db DbDetails, id int) (*Area, error) {
 		return nil, err
 	}
 
+	if len(area) == 0 {
+		return nil, nil
+	}
+
 	return &area[0], nil
 }
 
 func GetAreaRecordByName(db DbDetails, name string) (*Area, error) {
 	area := Area{}
-	err := db.FlygonDb.Get(&area, "SELECT id, name, pokemon_mode_workers, pokemon_mode_route, fort_mode_workers, fort_mode_route, quest_mode_workers, quest_mode_hours, quest_mode_route, geofence, enable_quests FROM area "+
+	err := db.FlygonDb.Get(&area, "SELECT id, name, pokemon_mode_workers, pokemon_mode_route, fort_mode_workers, fort_mode_route, quest_mode_workers, quest_mode_hours, quest_mode_route, geofence, enable_quests, enable_leveling, priority, weight, spillover, route_partition, account_groups FROM area "+
 		"WHERE name = ?", name)
 
 	if err == sql.ErrNoRows {
@@ -73,8 +89,8 @@ func GetAreaRecordByName(db DbDetails, name string) (*Area, error) {
 }
 
 func CreateArea(db DbDetails, area Area) (int64, error) {
-	res, err := db.FlygonDb.NamedExec("INSERT INTO area (name, pokemon_mode_workers, pokemon_mode_route, fort_mode_workers, fort_mode_route, quest_mode_workers, quest_mode_hours, quest_mode_route, geofence, enable_quests)"+
-		"VALUES (:name, :pokemon_mode_workers, :pokemon_mode_route, :fort_mode_workers, :fort_mode_route, :quest_mode_workers, :quest_mode_hours, :quest_mode_route, :geofence, :enable_quests)",
+	res, err := db.FlygonDb.NamedExec("INSERT INTO area (name, pokemon_mode_workers, pokemon_mode_route, fort_mode_workers, fort_mode_route, quest_mode_workers, quest_mode_hours, quest_mode_route, geofence, enable_quests, enable_leveling, priority, weight, spillover, route_partition, account_groups)"+
+		"VALUES (:name, :pokemon_mode_workers, :pokemon_mode_route, :fort_mode_workers, :fort_mode_route, :quest_mode_workers, :quest_mode_hours, :quest_mode_route, :geofence, :enable_quests, :enable_leveling, :priority, :weight, :spillover, :route_partition, :account_groups)",
 		area)
 
 	if err != nil {
@@ -95,7 +111,13 @@ func UpdateArea(db DbDetails, area Area) (int64, error) {
 		"quest_mode_hours = :quest_mode_hours, "+
 		"quest_mode_route = :quest_mode_route, "+
 		"geofence = :geofence, "+
-		"enable_quests = :enable_quests "+
+		"enable_quests = :enable_quests, "+
+		"enable_leveling = :enable_leveling, "+
+		"priority = :priority, "+
+		"weight = :weight, "+
+		"spillover = :spillover, "+
+		"route_partition = :route_partition, "+
+		"account_groups = :account_groups "+
 		"WHERE id = :id",
 		area)
 
@@ -122,6 +144,15 @@ func DeleteArea(db DbDetails, id int) (int64, error) {
 	return res.RowsAffected()
 }
 
+func SetPokemonModeRoute(db DbDetails, id int, route string) (int64, error) {
+	res, err := db.FlygonDb.Exec("UPDATE area SET pokemon_mode_route = ? WHERE id = ?", route, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
 func ParseRouteFromString(routeString string) ([]geo.Location, error) {
 	if routeString == "" {
 		return []geo.Location{}, nil
@@ -166,6 +197,23 @@ func CreateRouteString(locationList []geo.Location) string {
 	return routeString
 }
 
+// ParseAccountGroupsFromString returns the account groups of a comma separated list
+func ParseAccountGroupsFromString(groups string) []string {
+	response := []string{}
+
+	for _, group := range strings.Split(groups, ",") {
+		group = strings.TrimSpace(group)
+		if group != "" {
+			response = append(response, group)
+		}
+	}
+	return response
+}
+
+func CreateAccountGroupsString(groups []string) string {
+	return strings.Join(groups, ",")
+}
+
 func CreateQuestHoursString(hours []int) string {
 	// convert integer array to string
 	hoursString := ""
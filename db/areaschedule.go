@@ -0,0 +1,66 @@
+package db
+
+import (
+	"database/sql"
+)
+
+type AreaSchedule struct {
+	Id                 int    `db:"id"`
+	AreaId             int    `db:"area_id"`
+	Cron               string `db:"cron"`
+	PokemonModeWorkers int    `db:"pokemon_mode_workers"`
+	FortModeWorkers    int    `db:"fort_mode_workers"`
+	QuestMode          bool   `db:"quest_mode"`
+}
+
+func GetAreaScheduleRecords(db DbDetails) ([]AreaSchedule, error) {
+	schedules := []AreaSchedule{}
+	err := db.FlygonDb.Select(&schedules, "SELECT id, area_id, cron, pokemon_mode_workers, fort_mode_workers, quest_mode FROM area_schedule")
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func GetAreaScheduleRecordsForArea(db DbDetails, areaId int) ([]AreaSchedule, error) {
+	schedules := []AreaSchedule{}
+	err := db.FlygonDb.Select(&schedules, "SELECT id, area_id, cron, pokemon_mode_workers, fort_mode_workers, quest_mode FROM area_schedule "+
+		"WHERE area_id = ?", areaId)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func CreateAreaSchedule(db DbDetails, schedule AreaSchedule) (int64, error) {
+	res, err := db.FlygonDb.NamedExec("INSERT INTO area_schedule (area_id, cron, pokemon_mode_workers, fort_mode_workers, quest_mode) "+
+		"VALUES (:area_id, :cron, :pokemon_mode_workers, :fort_mode_workers, :quest_mode)",
+		schedule)
+
+	if err != nil {
+		return -1, err
+	}
+
+	return res.LastInsertId()
+}
+
+func DeleteAreaSchedule(db DbDetails, areaId int, id int) (int64, error) {
+	res, err := db.FlygonDb.Exec("DELETE FROM area_schedule WHERE area_id = ? AND id = ?", areaId, id)
+	if err != nil {
+		return -1, err
+	}
+
+	return res.RowsAffected()
+}
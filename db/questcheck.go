@@ -0,0 +1,42 @@
+package db
+
+import (
+	"database/sql"
+)
+
+type QuestCheck struct {
+	AreaId    int     `db:"area_id"`
+	Lat       float64 `db:"lat"`
+	Lon       float64 `db:"lon"`
+	Pokestops string  `db:"pokestops"`
+}
+
+func GetQuestCheckRecords(db DbDetails, areaId int) ([]QuestCheck, error) {
+	questChecks := []QuestCheck{}
+	err := db.FlygonDb.Select(&questChecks, "SELECT area_id, lat, lon, pokestops FROM quest_check WHERE area_id = ?", areaId)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return questChecks, nil
+}
+
+// SaveQuestCheck inserts or updates the quest check of a location in an area
+func SaveQuestCheck(db DbDetails, questCheck QuestCheck) error {
+	_, err := db.FlygonDb.NamedExec(
+		`INSERT INTO quest_check (area_id, lat, lon, pokestops)
+        VALUES (:area_id, :lat, :lon, :pokestops)
+        ON DUPLICATE KEY UPDATE pokestops = VALUES(pokestops)
+    `, questCheck)
+	return err
+}
+
+func DeleteQuestChecks(db DbDetails, areaId int) error {
+	_, err := db.FlygonDb.Exec("DELETE FROM quest_check WHERE area_id = ?", areaId)
+	return err
+}
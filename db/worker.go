@@ -0,0 +1,54 @@
+package db
+
+import (
+	"database/sql"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+type Worker struct {
+	Uuid      string      `db:"uuid"`
+	AreaId    int         `db:"area_id"`
+	Mode      int         `db:"mode"`
+	Username  null.String `db:"username"`
+	StartStep int         `db:"start_step"`
+	EndStep   int         `db:"end_step"`
+	Step      int         `db:"step"`
+	Host      string      `db:"host"`
+	LastSeen  int64       `db:"last_seen"`
+}
+
+func GetWorkerRecords(db DbDetails) ([]Worker, error) {
+	workers := []Worker{}
+	err := db.FlygonDb.Select(&workers, "SELECT uuid, area_id, mode, username, start_step, end_step, step, host, last_seen FROM worker")
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return workers, nil
+}
+
+func DeleteWorkersNotSeenSince(db DbDetails, lastSeen int64) error {
+	_, err := db.FlygonDb.Exec("DELETE FROM worker WHERE last_seen < ?", lastSeen)
+	return err
+}
+
+// SaveWorkers inserts or updates all given workers in one statement
+func SaveWorkers(db DbDetails, workers []Worker) error {
+	if len(workers) == 0 {
+		return nil
+	}
+	_, err := db.FlygonDb.NamedExec(
+		`INSERT INTO worker (uuid, area_id, mode, username, start_step, end_step, step, host, last_seen)
+        VALUES (:uuid, :area_id, :mode, :username, :start_step, :end_step, :step, :host, :last_seen)
+        ON DUPLICATE KEY UPDATE area_id = VALUES(area_id), mode = VALUES(mode), username = VALUES(username),
+        start_step = VALUES(start_step), end_step = VALUES(end_step), step = VALUES(step), host = VALUES(host),
+        last_seen = VALUES(last_seen)
+    `, workers)
+	return err
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"database/sql"
+)
+
+type WorkerAssignment struct {
+	Uuid   string `db:"uuid"`
+	AreaId int    `db:"area_id"`
+}
+
+func GetWorkerAssignmentRecords(db DbDetails) ([]WorkerAssignment, error) {
+	assignments := []WorkerAssignment{}
+	err := db.FlygonDb.Select(&assignments, "SELECT uuid, area_id FROM worker_assignment")
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return assignments, nil
+}
+
+func SetWorkerAssignment(db DbDetails, assignment WorkerAssignment) error {
+	_, err := db.FlygonDb.NamedExec("INSERT INTO worker_assignment (uuid, area_id) VALUES (:uuid, :area_id) "+
+		"ON DUPLICATE KEY UPDATE area_id = :area_id",
+		assignment)
+	return err
+}
+
+func DeleteWorkerAssignment(db DbDetails, uuid string) (int64, error) {
+	res, err := db.FlygonDb.Exec("DELETE FROM worker_assignment WHERE uuid = ?", uuid)
+	if err != nil {
+		return -1, err
+	}
+
+	return res.RowsAffected()
+}
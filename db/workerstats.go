@@ -0,0 +1,52 @@
+package db
+
+import (
+	"database/sql"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+type WorkerStats struct {
+	Datetime         int64       `db:"datetime"`
+	ControllerWorker string      `db:"controller_worker"`
+	DeviceName       null.String `db:"device_name"`
+	LocAvg           null.Float  `db:"loc_avg"`
+	LocCount         null.Int    `db:"loc_count"`
+	LocSuccess       null.Int    `db:"loc_success"`
+	MonsSeen         null.Int    `db:"mons_seen"`
+	MonsEnc          null.Int    `db:"mons_enc"`
+	Stops            null.Int    `db:"stops"`
+	Quests           null.Int    `db:"quests"`
+}
+
+// GetWorkerStatsRecords returns the statistics of a worker between the unix timestamps from and to, oldest first
+func GetWorkerStatsRecords(db DbDetails, uuid string, from int64, to int64) ([]WorkerStats, error) {
+	stats := []WorkerStats{}
+	err := db.FlygonDb.Select(&stats, "SELECT UNIX_TIMESTAMP(datetime) AS datetime, controller_worker, device_name, loc_avg, loc_count, loc_success, mons_seen, mons_enc, stops, quests FROM stats_workers "+
+		"WHERE controller_worker = ? AND datetime BETWEEN FROM_UNIXTIME(?) AND FROM_UNIXTIME(?) ORDER BY datetime", uuid, from, to)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// InsertWorkerStats inserts all given statistics in one statement
+func InsertWorkerStats(db DbDetails, stats []WorkerStats) error {
+	if len(stats) == 0 {
+		return nil
+	}
+	_, err := db.FlygonDb.NamedExec(
+		`INSERT INTO stats_workers (datetime, controller_worker, device_name, loc_avg, loc_count, loc_success, mons_seen, mons_enc, stops, quests)
+        VALUES (FROM_UNIXTIME(:datetime), :controller_worker, :device_name, :loc_avg, :loc_count, :loc_success, :mons_seen, :mons_enc, :stops, :quests)
+        ON DUPLICATE KEY UPDATE device_name = VALUES(device_name), loc_avg = VALUES(loc_avg), loc_count = VALUES(loc_count),
+        loc_success = VALUES(loc_success), mons_seen = VALUES(mons_seen), mons_enc = VALUES(mons_enc), stops = VALUES(stops),
+        quests = VALUES(quests)
+    `, stats)
+	return err
+}
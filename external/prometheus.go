@@ -23,6 +23,13 @@ var (
 		},
 		[]string{"status", "type"},
 	)
+	QuestStatus = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "quest_status",
+			Help: "Pokestops of an area with an AR quest (quests), a non-AR quest (alt_quests) and in total (total)",
+		},
+		[]string{"area", "type"},
+	)
 )
 
 func InitPrometheus(r *gin.Engine) {
@@ -39,7 +46,7 @@ func InitPrometheus(r *gin.Engine) {
 		r.Use(p.Instrument())
 
 		prometheus.MustRegister(
-			RawRequests, ControllerRequests,
+			RawRequests, ControllerRequests, QuestStatus,
 		)
 	}
 }
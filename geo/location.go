@@ -1,5 +1,7 @@
 package geo
 
+import "math"
+
 type Location struct {
 	Latitude  float64
 	Longitude float64
@@ -25,3 +27,17 @@ func SplitRoute(route []Location, parts int) [][]Location {
 
 	return routes
 }
+
+const earthRadiusMeters = 6371000.0
+
+// Distance returns the great circle distance between both locations in meters
+func (l Location) Distance(other Location) float64 {
+	lat1 := l.Latitude * math.Pi / 180
+	lat2 := other.Latitude * math.Pi / 180
+	deltaLat := (other.Latitude - l.Latitude) * math.Pi / 180
+	deltaLon := (other.Longitude - l.Longitude) * math.Pi / 180
+
+	a := math.Sin(deltaLat/2)*math.Sin(deltaLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(deltaLon/2)*math.Sin(deltaLon/2)
+	return earthRadiusMeters * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,226 @@
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+// kMeansIterations limits the refinement rounds of PartitionRoute
+const kMeansIterations = 10
+
+// planarPoint is a location projected onto a plane in meters, good enough for distances within a city
+type planarPoint struct {
+	x float64
+	y float64
+}
+
+// planarProjection is an equirectangular projection around a reference latitude
+type planarProjection struct {
+	cosRefLat float64
+}
+
+func newPlanarProjection(locations []Location) planarProjection {
+	refLat := 0.0
+	for _, l := range locations {
+		refLat += l.Latitude
+	}
+	if len(locations) > 0 {
+		refLat /= float64(len(locations))
+	}
+	return planarProjection{cosRefLat: math.Cos(refLat * math.Pi / 180)}
+}
+
+func (p planarProjection) project(l Location) planarPoint {
+	return planarPoint{
+		x: l.Longitude * math.Pi / 180 * p.cosRefLat * earthRadiusMeters,
+		y: l.Latitude * math.Pi / 180 * earthRadiusMeters,
+	}
+}
+
+func (p planarProjection) unproject(point planarPoint) Location {
+	return Location{
+		Latitude:  point.y / earthRadiusMeters * 180 / math.Pi,
+		Longitude: point.x / earthRadiusMeters / p.cosRefLat * 180 / math.Pi,
+	}
+}
+
+func projectLocations(locations []Location) []planarPoint {
+	projection := newPlanarProjection(locations)
+	points := make([]planarPoint, len(locations))
+	for i, l := range locations {
+		points[i] = projection.project(l)
+	}
+	return points
+}
+
+func (p planarPoint) distance(other planarPoint) float64 {
+	return math.Hypot(p.x-other.x, p.y-other.y)
+}
+
+// PartitionRoute splits the route into compact geographic clusters of (almost) equal size with balanced k-means.
+// Every cluster is a list of route indexes in walking order, neighbouring clusters follow each other
+func PartitionRoute(route []Location, parts int) [][]int {
+	if parts <= 0 || len(route) == 0 {
+		return nil
+	}
+	if parts > len(route) {
+		parts = len(route)
+	}
+
+	points := projectLocations(route)
+	centroids := initialCentroids(points, parts)
+	assignment := make([]int, len(points))
+	for iteration := 0; iteration < kMeansIterations; iteration++ {
+		changed := assignBalanced(points, centroids, assignment, iteration == 0)
+		centroids = updateCentroids(points, assignment, parts)
+		if !changed {
+			break
+		}
+	}
+
+	clusters := make([][]int, parts)
+	for i, cluster := range assignment {
+		clusters[cluster] = append(clusters[cluster], i)
+	}
+
+	// walk the clusters in a nearest neighbour order of their centroids
+	clusterOrder := nearestNeighbourOrder(centroids, 0)
+	ordered := make([][]int, 0, parts)
+	previous := -1
+	for _, c := range clusterOrder {
+		cluster := clusters[c]
+		start := 0
+		if previous >= 0 {
+			start = nearestIndex(points, cluster, points[previous])
+		}
+		clusterPoints := make([]planarPoint, len(cluster))
+		for i, index := range cluster {
+			clusterPoints[i] = points[index]
+		}
+		order := nearestNeighbourOrder(clusterPoints, start)
+		walk := make([]int, len(order))
+		for i, o := range order {
+			walk[i] = cluster[o]
+		}
+		ordered = append(ordered, walk)
+		previous = walk[len(walk)-1]
+	}
+
+	return ordered
+}
+
+// initialCentroids spreads the starting centroids with farthest point sampling
+func initialCentroids(points []planarPoint, k int) []planarPoint {
+	centroids := []planarPoint{points[0]}
+	minDistance := make([]float64, len(points))
+	for i, p := range points {
+		minDistance[i] = p.distance(points[0])
+	}
+	for len(centroids) < k {
+		farthest := 0
+		for i := range points {
+			if minDistance[i] > minDistance[farthest] {
+				farthest = i
+			}
+		}
+		centroids = append(centroids, points[farthest])
+		for i, p := range points {
+			minDistance[i] = math.Min(minDistance[i], p.distance(points[farthest]))
+		}
+	}
+	return centroids
+}
+
+// assignBalanced assigns every point to the nearest centroid with capacity left, shortest distances first
+func assignBalanced(points []planarPoint, centroids []planarPoint, assignment []int, initial bool) bool {
+	type candidate struct {
+		point    int
+		cluster  int
+		distance float64
+	}
+	candidates := make([]candidate, 0, len(points)*len(centroids))
+	for i, p := range points {
+		for c, centroid := range centroids {
+			candidates = append(candidates, candidate{point: i, cluster: c, distance: p.distance(centroid)})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	// the first len(points) % k clusters get one point more
+	k := len(centroids)
+	capacity := make([]int, k)
+	for c := range capacity {
+		capacity[c] = len(points) / k
+		if c < len(points)%k {
+			capacity[c]++
+		}
+	}
+
+	assigned := make([]bool, len(points))
+	changed := initial
+	for _, candidate := range candidates {
+		if assigned[candidate.point] || capacity[candidate.cluster] == 0 {
+			continue
+		}
+		assigned[candidate.point] = true
+		capacity[candidate.cluster]--
+		if assignment[candidate.point] != candidate.cluster {
+			assignment[candidate.point] = candidate.cluster
+			changed = true
+		}
+	}
+	return changed
+}
+
+func updateCentroids(points []planarPoint, assignment []int, k int) []planarPoint {
+	centroids := make([]planarPoint, k)
+	counts := make([]int, k)
+	for i, p := range points {
+		centroids[assignment[i]].x += p.x
+		centroids[assignment[i]].y += p.y
+		counts[assignment[i]]++
+	}
+	for c := range centroids {
+		if counts[c] > 0 {
+			centroids[c].x /= float64(counts[c])
+			centroids[c].y /= float64(counts[c])
+		}
+	}
+	return centroids
+}
+
+func nearestIndex(points []planarPoint, indexes []int, from planarPoint) int {
+	best := 0
+	for i, index := range indexes {
+		if points[index].distance(from) < points[indexes[best]].distance(from) {
+			best = i
+		}
+	}
+	return best
+}
+
+// nearestNeighbourOrder returns the order of the points visiting always the nearest unvisited point next
+func nearestNeighbourOrder(points []planarPoint, start int) []int {
+	if len(points) == 0 {
+		return nil
+	}
+	visited := make([]bool, len(points))
+	order := make([]int, 0, len(points))
+	current := start
+	for {
+		visited[current] = true
+		order = append(order, current)
+		next := -1
+		for i, p := range points {
+			if !visited[i] && (next == -1 || p.distance(points[current]) < points[next].distance(points[current])) {
+				next = i
+			}
+		}
+		if next == -1 {
+			return order
+		}
+		current = next
+	}
+}
@@ -0,0 +1,68 @@
+package geo
+
+import "testing"
+
+// gridRoute returns a route of rows x columns locations about 100m apart
+func gridRoute(rows int, columns int) []Location {
+	route := make([]Location, 0, rows*columns)
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			route = append(route, Location{Latitude: 52.5 + float64(row)*0.0009, Longitude: 13.4 + float64(column)*0.0015})
+		}
+	}
+	return route
+}
+
+func TestPartitionRoute(t *testing.T) {
+	tests := []struct {
+		name  string
+		route []Location
+		parts int
+		want  int
+	}{
+		{"single part", gridRoute(10, 10), 1, 1},
+		{"even parts", gridRoute(10, 10), 4, 4},
+		{"uneven parts", gridRoute(10, 10), 7, 7},
+		{"narrow route", gridRoute(1, 25), 4, 4},
+		{"more parts than steps", gridRoute(2, 3), 10, 6},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clusters := PartitionRoute(test.route, test.parts)
+			if len(clusters) != test.want {
+				t.Fatalf("PartitionRoute returned %d clusters, want %d", len(clusters), test.want)
+			}
+
+			minSize, maxSize := len(test.route), 0
+			seen := make([]bool, len(test.route))
+			for _, cluster := range clusters {
+				minSize = min(minSize, len(cluster))
+				maxSize = max(maxSize, len(cluster))
+				for _, index := range cluster {
+					if seen[index] {
+						t.Errorf("step %d is part of more than one cluster", index)
+					}
+					seen[index] = true
+				}
+			}
+			if maxSize-minSize > 1 {
+				t.Errorf("cluster sizes range from %d to %d, want a difference of at most 1", minSize, maxSize)
+			}
+			for index, found := range seen {
+				if !found {
+					t.Errorf("step %d is not part of any cluster", index)
+				}
+			}
+		})
+	}
+}
+
+func TestPartitionRouteWithoutParts(t *testing.T) {
+	if clusters := PartitionRoute(gridRoute(3, 3), 0); clusters != nil {
+		t.Errorf("PartitionRoute with 0 parts returned %v, want nil", clusters)
+	}
+	if clusters := PartitionRoute(nil, 3); clusters != nil {
+		t.Errorf("PartitionRoute of an empty route returned %v, want nil", clusters)
+	}
+}
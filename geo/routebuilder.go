@@ -0,0 +1,195 @@
+package geo
+
+import (
+	"errors"
+	"math"
+)
+
+// maxTwoOptPasses limits the optimisation of OptimizeRoute on large routes
+const maxTwoOptPasses = 50
+
+// maxTwoOptComparisons limits the work of all 2-opt passes, a pass compares every pair of route steps
+const maxTwoOptComparisons = 50_000_000
+
+// maxRouteGridCells limits the hexagonal grid cells BuildRoute checks against the fence
+const maxRouteGridCells = 1_000_000
+
+// maxRoutePoints limits the steps of a route built by BuildRoute, so it can be ordered in a few seconds
+const maxRoutePoints = 10_000
+
+var ErrRouteTooLarge = errors.New("route would be too large, use a larger radius or a smaller fence")
+
+// BuildRoute covers the geofence with scan circles of the given radius in meters. The circle centres are
+// placed on a hexagonal grid and every circle touching the fence is part of the route, ordered by OptimizeRoute.
+// ErrRouteTooLarge is returned if the route would have more than maxRoutePoints steps
+func BuildRoute(fence Geofence, radius float64) ([]Location, error) {
+	if len(fence.Fence) < 3 || radius <= 0 {
+		return nil, nil
+	}
+
+	projection := newPlanarProjection(fence.Fence)
+	polygon := make([]planarPoint, len(fence.Fence))
+	for i, l := range fence.Fence {
+		polygon[i] = projection.project(l)
+	}
+
+	minX, minY := polygon[0].x, polygon[0].y
+	maxX, maxY := minX, minY
+	for _, p := range polygon {
+		minX = math.Min(minX, p.x)
+		minY = math.Min(minY, p.y)
+		maxX = math.Max(maxX, p.x)
+		maxY = math.Max(maxY, p.y)
+	}
+
+	// circles on a hexagonal grid cover the plane without gaps
+	dx := radius * math.Sqrt(3)
+	dy := radius * 1.5
+	if ((maxX-minX)/dx+2)*((maxY-minY)/dy+2) > maxRouteGridCells {
+		return nil, ErrRouteTooLarge
+	}
+	var route []Location
+	for row := 0; minY-radius+float64(row)*dy <= maxY+radius; row++ {
+		y := minY - radius + float64(row)*dy
+		offset := 0.0
+		if row%2 == 1 {
+			offset = dx / 2
+		}
+		for x := minX - radius + offset; x <= maxX+radius; x += dx {
+			centre := planarPoint{x: x, y: y}
+			location := projection.unproject(centre)
+			if fence.Contains(location) || distanceToPolygon(centre, polygon) <= radius {
+				route = append(route, location)
+			}
+		}
+		if len(route) > maxRoutePoints {
+			return nil, ErrRouteTooLarge
+		}
+	}
+
+	return OptimizeRoute(route), nil
+}
+
+// OptimizeRoute orders the locations as a short round trip, built with nearest neighbour and improved with 2-opt
+func OptimizeRoute(route []Location) []Location {
+	if len(route) < 3 {
+		return route
+	}
+
+	points := projectLocations(route)
+	tour := nearestNeighbourOrder(points, 0)
+	twoOpt(points, tour)
+
+	ordered := make([]Location, len(tour))
+	for i, index := range tour {
+		ordered[i] = route[index]
+	}
+	return ordered
+}
+
+// twoOpt reverses segments of the round trip as long as this shortens it, large routes get fewer passes
+func twoOpt(points []planarPoint, tour []int) {
+	n := len(tour)
+	comparisons := 0
+	for pass := 0; pass < maxTwoOptPasses && comparisons < maxTwoOptComparisons; pass++ {
+		comparisons += n * n / 2
+		improved := false
+		for i := 0; i < n-1; i++ {
+			a, b := points[tour[i]], points[tour[i+1]]
+			for j := i + 2; j < n; j++ {
+				if i == 0 && j == n-1 {
+					continue
+				}
+				c, d := points[tour[j]], points[tour[(j+1)%n]]
+				if a.distance(c)+b.distance(d) < a.distance(b)+c.distance(d)-1e-9 {
+					for left, right := i+1, j; left < right; left, right = left+1, right-1 {
+						tour[left], tour[right] = tour[right], tour[left]
+					}
+					b = points[tour[i+1]]
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
+
+func distanceToPolygon(p planarPoint, polygon []planarPoint) float64 {
+	best := math.MaxFloat64
+	for i := range polygon {
+		best = math.Min(best, distanceToSegment(p, polygon[i], polygon[(i+1)%len(polygon)]))
+	}
+	return best
+}
+
+func distanceToSegment(p planarPoint, a planarPoint, b planarPoint) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	lengthSquared := dx*dx + dy*dy
+	if lengthSquared == 0 {
+		return p.distance(a)
+	}
+	t := ((p.x-a.x)*dx + (p.y-a.y)*dy) / lengthSquared
+	t = math.Max(0, math.Min(1, t))
+	return p.distance(planarPoint{x: a.x + t*dx, y: a.y + t*dy})
+}
+
+// ClusterLocations reduces the locations to as few points as possible, so every location is within the
+// radius of one point. Points are picked greedily by the number of locations they cover, moved to the centre
+// of their locations if that still covers all of them
+func ClusterLocations(locations []Location, radius float64) []Location {
+	points := projectLocations(locations)
+	projection := newPlanarProjection(locations)
+
+	neighbours := make([][]int, len(points))
+	for i := range points {
+		for j := range points {
+			if points[i].distance(points[j]) <= radius {
+				neighbours[i] = append(neighbours[i], j)
+			}
+		}
+	}
+
+	covered := make([]bool, len(points))
+	remaining := len(points)
+	var clusters []Location
+	for remaining > 0 {
+		best, bestCount := -1, 0
+		for i := range points {
+			count := 0
+			for _, j := range neighbours[i] {
+				if !covered[j] {
+					count++
+				}
+			}
+			if count > bestCount {
+				best, bestCount = i, count
+			}
+		}
+
+		var members []planarPoint
+		for _, j := range neighbours[best] {
+			if !covered[j] {
+				covered[j] = true
+				remaining--
+				members = append(members, points[j])
+			}
+		}
+
+		centre := planarPoint{}
+		for _, m := range members {
+			centre.x += m.x / float64(len(members))
+			centre.y += m.y / float64(len(members))
+		}
+		for _, m := range members {
+			if m.distance(centre) > radius {
+				centre = points[best]
+				break
+			}
+		}
+		clusters = append(clusters, projection.unproject(centre))
+	}
+
+	return clusters
+}
@@ -0,0 +1,109 @@
+package geo
+
+import "testing"
+
+func TestOptimizeRouteKeepsEveryLocation(t *testing.T) {
+	route := gridRoute(8, 9)
+	// shuffle deterministically, so the optimisation has something to do
+	for x := range route {
+		y := (x * 37) % len(route)
+		route[x], route[y] = route[y], route[x]
+	}
+
+	optimized := OptimizeRoute(route)
+	if len(optimized) != len(route) {
+		t.Fatalf("OptimizeRoute returned %d locations, want %d", len(optimized), len(route))
+	}
+	counts := make(map[Location]int)
+	for _, l := range route {
+		counts[l]++
+	}
+	for _, l := range optimized {
+		counts[l]--
+	}
+	for l, count := range counts {
+		if count != 0 {
+			t.Errorf("location %v is %d times more often in the input than in the optimized route", l, count)
+		}
+	}
+}
+
+func TestBuildRouteCoversFence(t *testing.T) {
+	const radius = 70.0
+	fence := Geofence{Fence: []Location{
+		{Latitude: 52.50, Longitude: 13.40},
+		{Latitude: 52.51, Longitude: 13.40},
+		{Latitude: 52.51, Longitude: 13.42},
+		{Latitude: 52.505, Longitude: 13.41},
+		{Latitude: 52.50, Longitude: 13.42},
+	}}
+
+	route, err := BuildRoute(fence, radius)
+	if err != nil {
+		t.Fatalf("BuildRoute returned error %s", err)
+	}
+	if len(route) == 0 {
+		t.Fatal("BuildRoute returned an empty route")
+	}
+
+	seen := make(map[Location]bool)
+	for _, l := range route {
+		if seen[l] {
+			t.Errorf("location %v is part of the route more than once", l)
+		}
+		seen[l] = true
+	}
+
+	// every point of the fence is within the radius of a route location, with some tolerance for the projection
+	for lat := 52.50; lat <= 52.51; lat += 0.0002 {
+		for lon := 13.40; lon <= 13.42; lon += 0.0002 {
+			point := Location{Latitude: lat, Longitude: lon}
+			if !fence.Contains(point) {
+				continue
+			}
+			covered := false
+			for _, l := range route {
+				if l.Distance(point) <= radius*1.01 {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				t.Errorf("point %v of the fence is not covered by the route", point)
+			}
+		}
+	}
+}
+
+func TestBuildRouteInvalidInput(t *testing.T) {
+	fence := Geofence{Fence: []Location{{Latitude: 52.50, Longitude: 13.40}, {Latitude: 52.51, Longitude: 13.40}}}
+	if route, err := BuildRoute(fence, 70); route != nil || err != nil {
+		t.Errorf("BuildRoute of a fence with 2 points returned %d locations and error %v, want none", len(route), err)
+	}
+}
+
+func TestBuildRouteTooLarge(t *testing.T) {
+	fence := Geofence{Fence: []Location{
+		{Latitude: 52.0, Longitude: 13.0},
+		{Latitude: 53.0, Longitude: 13.0},
+		{Latitude: 53.0, Longitude: 14.0},
+		{Latitude: 52.0, Longitude: 14.0},
+	}}
+
+	tests := []struct {
+		name   string
+		radius float64
+	}{
+		// about 1.2 million grid cells
+		{"too many grid cells", 50},
+		// about 12,000 grid cells, most of them inside the fence
+		{"too many route points", 500},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := BuildRoute(fence, test.radius); err != ErrRouteTooLarge {
+				t.Errorf("BuildRoute with radius %.0f returned error %v, want %v", test.radius, err, ErrRouteTooLarge)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package golbatapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"flygon/geo"
+	"flygon/util"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+)
+
+type PokestopPosition struct {
+	Id        string  `json:"id"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// GetPokestopPositions returns all pokestops Golbat knows inside the geofence
+func GetPokestopPositions(geofence geo.Geofence) ([]PokestopPosition, error) {
+	if golbatUrl == "" {
+		return nil, errors.New("no golbat url defined")
+	}
+	if len(geofence.Fence) == 0 {
+		return nil, errors.New("empty geofence")
+	}
+
+	// the fence belongs to the area, closing the ring must not write into its backing array
+	locations := slices.Clone(geofence.Fence)
+	if locations[0] != locations[len(locations)-1] {
+		locations = append(locations, locations[0])
+	}
+
+	fence := []ApiLocation{}
+	for _, loc := range locations {
+		fence = append(fence, ApiLocation{
+			Latitude:  loc.Latitude,
+			Longitude: loc.Longitude,
+		})
+	}
+
+	fenceBytes, err := json.Marshal(&fence)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal golbat request")
+	}
+
+	url := util.JoinUrl(golbatUrl, "/api/pokestop-positions")
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(fenceBytes))
+	if err != nil {
+		log.Warnf("Webhook: unable to create new Request to %s - %s", url, err)
+		return nil, errors.Wrap(err, "unable to create new Request to golbat")
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	if apiSecret != "" {
+		req.Header.Set("X-Golbat-Secret", apiSecret)
+	}
+
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		log.Warnf("Webhook: unable to connect to %s - %s", url, err)
+		return nil, errors.Wrap(err, "unable to connect to golbat")
+	}
+	defer res.Body.Close()
+
+	log.Debugf("Webhook: Response %s", res.Status)
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("golbat responded with %s", res.Status)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var positions []PokestopPosition
+	if err := json.Unmarshal(body, &positions); err != nil {
+		return nil, err
+	}
+
+	return positions, nil
+}
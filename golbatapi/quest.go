@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"flygon/geo"
 	"flygon/util"
@@ -75,7 +76,7 @@ func ClearQuests(geofence geo.Geofence) error {
 		log.Warnf("Webhook: unable to connect to %s - %s", url, err)
 		return errors.Wrap(err, "unable to connect to golbat")
 	}
-	defer req.Body.Close()
+	defer res.Body.Close()
 
 	log.Debugf("Webhook: Response %s", res.Status)
 
@@ -85,7 +86,7 @@ func ClearQuests(geofence geo.Geofence) error {
 func GetQuestStatus(geofence []geo.Location) (QuestStatus, error) {
 	var questStatus QuestStatus
 
-	if golbatUrl == "" {
+	if golbatUrl == "" || len(geofence) == 0 {
 		return questStatus, nil
 	}
 
@@ -127,12 +128,18 @@ func GetQuestStatus(geofence []geo.Location) (QuestStatus, error) {
 		req.Header.Set("X-Golbat-Secret", apiSecret)
 	}
 
-	httpClient := &http.Client{}
+	httpClient := &http.Client{Timeout: 30 * time.Second}
 	res, err := httpClient.Do(req)
-
-	defer req.Body.Close()
+	if err != nil {
+		log.Warnf("Webhook: unable to connect to %s - %s", url, err)
+		return questStatus, errors.Wrap(err, "unable to connect to golbat")
+	}
+	defer res.Body.Close()
 
 	log.Debugf("Webhook: Response %s", res.Status)
+	if res.StatusCode != http.StatusOK {
+		return questStatus, errors.Errorf("golbat responded with %s", res.Status)
+	}
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return questStatus, err
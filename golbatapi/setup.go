@@ -8,3 +8,8 @@ func SetApiUrl(url string, secret string) {
 	golbatUrl = url
 	apiSecret = secret
 }
+
+// IsConfigured returns true if a Golbat API URL is set
+func IsConfigured() bool {
+	return golbatUrl != ""
+}
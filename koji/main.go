@@ -30,7 +30,7 @@ func setAreas(details *db.DbDetails, kojiFenceRef RefData) {
 	area, err := db.GetAreaRecordByName(*details, kojiFenceRef.Name)
 
 	if err != nil {
-		area = &db.Area{Name: kojiFenceRef.Name, Id: 0}
+		area = &db.Area{Name: kojiFenceRef.Name, Id: 0, Weight: 1, RoutePartition: db.RoutePartitionIndex}
 	}
 
 	kojiFence, err := request[string](
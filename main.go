@@ -13,7 +13,10 @@ import (
 	"flygon/util"
 	"flygon/worker"
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -71,19 +74,39 @@ func main() {
 
 	routes.ConnectDatabase(&dbDetails)
 	routes.LoadAccountManager(&am)
-	worker.StartAreas(dbDetails)
-	worker.InitWorkerState()
-	worker.SetWorkerUnseen()
 	if config.Config.Processors.GolbatEndpoint != "" {
 		golbatapi.SetApiUrl(config.Config.Processors.GolbatEndpoint,
 			config.Config.Processors.GolbatApiSecret)
 	}
+	worker.InitWorkerState()
+	worker.SetWorkerUnseen()
+	worker.StartAreas(dbDetails)
 	worker.SetRequestLimits(requestLimits)
+	worker.RestoreWorkerState(dbDetails, am.ReserveAccount)
+	worker.StartWorkerStateSaver(dbDetails)
+	worker.StartWorkerStateJanitor(dbDetails, am.ReleaseAccount)
+	worker.StartWorkerStatsWriter(dbDetails)
+	saveWorkerStateOnShutdown(dbDetails)
 	routes.SetRawEndpoints(getRawEndpointsFromConfig())
 	routes.StartGin()
 
 }
 
+// saveWorkerStateOnShutdown persists the worker states and stats when Flygon is stopped, so a restart resumes all devices
+func saveWorkerStateOnShutdown(dbDetails db.DbDetails) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log.Info("Saving worker states before shutdown")
+		worker.SaveWorkerState(dbDetails)
+		if worker.WorkerStatsEnabled() {
+			worker.SaveWorkerStats(dbDetails)
+		}
+		os.Exit(0)
+	}()
+}
+
 func connectDb(dbDetails config.DbDefinition) *sqlx.DB {
 	dbConnectionString := createConnectionString(dbDetails)
 	driver := "mysql"
@@ -0,0 +1,283 @@
+package routes
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"flygon/accounts"
+	"flygon/db"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v4"
+)
+
+// maxAccountImportSize is the maximum size in bytes of an account list upload
+const maxAccountImportSize = 10 << 20
+
+// maxAccountFieldLength is the length in characters of the username and password columns
+const maxAccountFieldLength = 32
+
+// noDefaultLevel is the default level of an import without level parameter, every line needs its own level then
+const noDefaultLevel = -1
+
+type ApiAccountImportLine struct {
+	Line     int    `json:"line"`
+	Username string `json:"username"`
+	Error    string `json:"error"`
+}
+
+type ApiAccountImportReport struct {
+	Imported int64                  `json:"imported"`
+	Invalid  int                    `json:"invalid"`
+	Errors   []ApiAccountImportLine `json:"errors"`
+}
+
+// parseAccountLine reads one line of an account list, either "username:password" or a CSV
+// line "username,password[,level]". Without level the default level is used, lines without level are
+// rejected if there is no default level
+func parseAccountLine(line string, defaultLevel int) (db.NewAccountRow, error) {
+	var fields []string
+	colon := strings.Index(line, ":")
+	comma := strings.IndexAny(line, ",\"")
+	if comma >= 0 && (colon < 0 || comma < colon) {
+		record, err := csv.NewReader(strings.NewReader(line)).Read()
+		if err != nil {
+			return db.NewAccountRow{}, errors.New("malformed csv line")
+		}
+		fields = record
+	} else {
+		fields = strings.SplitN(line, ":", 2)
+	}
+	for x := range fields {
+		fields[x] = strings.TrimSpace(fields[x])
+	}
+
+	account := db.NewAccountRow{Username: fields[0], Level: defaultLevel}
+	if len(fields) < 2 || fields[0] == "" || fields[1] == "" {
+		return account, errors.New("username and password are required")
+	}
+	if len(fields) > 3 {
+		return account, errors.New("too many fields")
+	}
+	account.Password = fields[1]
+	if utf8.RuneCountInString(account.Username) > maxAccountFieldLength {
+		return account, fmt.Errorf("username is longer than %d characters", maxAccountFieldLength)
+	}
+	if utf8.RuneCountInString(account.Password) > maxAccountFieldLength {
+		return account, fmt.Errorf("password is longer than %d characters", maxAccountFieldLength)
+	}
+	if len(fields) > 2 && fields[2] != "" {
+		level, err := strconv.Atoi(fields[2])
+		if err != nil || level < 0 || level > 50 {
+			return account, fmt.Errorf("invalid level '%s'", fields[2])
+		}
+		account.Level = level
+	} else if defaultLevel == noDefaultLevel {
+		return account, errors.New("level is required, add it to the line or set the level parameter")
+	}
+	return account, nil
+}
+
+// importAccountsReader returns the uploaded account list, either a multipart file "file" or the request body
+func importAccountsReader(c *gin.Context) (io.ReadCloser, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		if fileHeader.Size > maxAccountImportSize {
+			return nil, fmt.Errorf("account list is larger than %d bytes", maxAccountImportSize)
+		}
+		return fileHeader.Open()
+	}
+	return http.MaxBytesReader(c.Writer, c.Request.Body, maxAccountImportSize), nil
+}
+
+// PostAccountImport imports a plain text or CSV account list. Every line is validated on its own, valid accounts
+// are imported and the report lists all lines which were skipped. The query parameters level and group set
+// the level of lines without level and the account group of all accounts. Without level parameter every line
+// needs a level, as level 0 accounts would only be used for leveling
+func PostAccountImport(c *gin.Context) {
+	defaultLevel := noDefaultLevel
+	if levelParam := c.Query("level"); levelParam != "" {
+		level, err := strconv.Atoi(levelParam)
+		if err != nil || level < 0 || level > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "level has to be between 0 and 50"})
+			return
+		}
+		defaultLevel = level
+	}
+	group := strings.TrimSpace(c.Query("group"))
+	if err := validateAccountGroup(group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reader, err := importAccountsReader(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer reader.Close()
+
+	report := ApiAccountImportReport{Errors: []ApiAccountImportLine{}}
+	newAccounts := []db.NewAccountRow{}
+	seen := make(map[string]int)
+
+	scanner := bufio.NewScanner(reader)
+	lineNo := 0
+	firstEntry := true
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if firstEntry {
+			firstEntry = false
+			if strings.HasPrefix(strings.ToLower(line), "username,") {
+				// csv header
+				continue
+			}
+		}
+
+		account, err := parseAccountLine(line, defaultLevel)
+		if err == nil {
+			if firstLine, duplicate := seen[account.Username]; duplicate {
+				err = fmt.Errorf("duplicate of line %d", firstLine)
+			} else if accountManager.AccountExists(account.Username) {
+				err = errors.New("account already exists")
+			}
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, ApiAccountImportLine{
+				Line:     lineNo,
+				Username: account.Username,
+				Error:    err.Error(),
+			})
+			continue
+		}
+
+		seen[account.Username] = lineNo
+		account.AccountGroup = null.NewString(group, group != "")
+		newAccounts = append(newAccounts, account)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Warnf("POST /accounts/import Error during api %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	report.Invalid = len(report.Errors)
+
+	if len(newAccounts) > 0 {
+		report.Imported, err = db.InsertBulkAccounts(*dbDetails, newAccounts)
+		if err != nil {
+			log.Warnf("POST /accounts/import Error during api %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		accountManager.ReloadAccounts()
+	}
+	log.Infof("Imported %d accounts, %d lines were invalid", report.Imported, report.Invalid)
+
+	c.JSON(http.StatusAccepted, report)
+}
+
+type ApiAccountExport struct {
+	Username            string `json:"username"`
+	Password            string `json:"password"`
+	Level               int    `json:"level"`
+	Group               string `json:"group"`
+	InUse               bool   `json:"in_use"`
+	Banned              bool   `json:"banned"`
+	Suspended           bool   `json:"suspended"`
+	Invalid             bool   `json:"invalid"`
+	Warn                bool   `json:"warn"`
+	Disabled            bool   `json:"disabled"`
+	ConsecutiveDisabled int    `json:"consecutive_disabled"`
+	LastSelected        int64  `json:"last_selected"`
+}
+
+var accountExportCsvHeader = []string{"username", "password", "level", "group", "in_use", "banned", "suspended",
+	"invalid", "warn", "disabled", "consecutive_disabled", "last_selected"}
+
+func (a ApiAccountExport) csvRecord() []string {
+	return []string{
+		a.Username,
+		a.Password,
+		strconv.Itoa(a.Level),
+		a.Group,
+		strconv.FormatBool(a.InUse),
+		strconv.FormatBool(a.Banned),
+		strconv.FormatBool(a.Suspended),
+		strconv.FormatBool(a.Invalid),
+		strconv.FormatBool(a.Warn),
+		strconv.FormatBool(a.Disabled),
+		strconv.Itoa(a.ConsecutiveDisabled),
+		strconv.FormatInt(a.LastSelected, 10),
+	}
+}
+
+func accountStatusToExport(account accounts.AccountStatus, now int64) ApiAccountExport {
+	return ApiAccountExport{
+		Username:            account.DbRow.Username,
+		Password:            account.DbRow.Password,
+		Level:               account.DbRow.Level,
+		Group:               account.DbRow.AccountGroup.ValueOrZero(),
+		InUse:               account.InUse,
+		Banned:              account.DbRow.Banned,
+		Suspended:           account.DbRow.Suspended,
+		Invalid:             account.DbRow.Invalid,
+		Warn:                int64(account.DbRow.WarnExpiration) > now,
+		Disabled:            accounts.DisabledUntil(account.DbRow) > now,
+		ConsecutiveDisabled: account.DbRow.ConsecutiveDisabled,
+		LastSelected:        account.DbRow.LastSelected.ValueOrZero(),
+	}
+}
+
+// GetAccountExport streams all accounts with their status, the query parameter format selects csv (default) or jsonl
+func GetAccountExport(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format has to be 'csv' or 'jsonl'"})
+		return
+	}
+
+	now := time.Now().Unix()
+	accountDetails := accountManager.GetAccountDetails()
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=accounts.%s", format))
+	if format == "jsonl" {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+		for _, account := range accountDetails {
+			if err := encoder.Encode(accountStatusToExport(account, now)); err != nil {
+				log.Warnf("GET /accounts/export Error during api %v", err)
+				return
+			}
+		}
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.Write(accountExportCsvHeader)
+	for _, account := range accountDetails {
+		if err := writer.Write(accountStatusToExport(account, now).csvRecord()); err != nil {
+			log.Warnf("GET /accounts/export Error during api %v", err)
+			return
+		}
+	}
+	writer.Flush()
+}
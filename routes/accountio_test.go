@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"strings"
+	"testing"
+
+	"flygon/db"
+)
+
+func TestParseAccountLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		account db.NewAccountRow
+		valid   bool
+	}{
+		{"colon", "user1:secret", db.NewAccountRow{Username: "user1", Password: "secret", Level: 5}, true},
+		{"colon in password", "user1:sec:ret", db.NewAccountRow{Username: "user1", Password: "sec:ret", Level: 5}, true},
+		{"colon with spaces", " user1 : secret ", db.NewAccountRow{Username: "user1", Password: "secret", Level: 5}, true},
+		{"csv", "user1,secret", db.NewAccountRow{Username: "user1", Password: "secret", Level: 5}, true},
+		{"csv with level", "user1,secret,30", db.NewAccountRow{Username: "user1", Password: "secret", Level: 30}, true},
+		{"csv with empty level", "user1,secret,", db.NewAccountRow{Username: "user1", Password: "secret", Level: 5}, true},
+		{"csv quoted comma", `user1,"sec,ret"`, db.NewAccountRow{Username: "user1", Password: "sec,ret", Level: 5}, true},
+		{"csv quoted colon", `user1,"sec:ret"`, db.NewAccountRow{Username: "user1", Password: "sec:ret", Level: 5}, true},
+		{"csv quoted quote", `user1,"sec""ret"`, db.NewAccountRow{Username: "user1", Password: `sec"ret`, Level: 5}, true},
+		{"level 0", "user1,secret,0", db.NewAccountRow{Username: "user1", Password: "secret", Level: 0}, true},
+		{"level 50", "user1,secret,50", db.NewAccountRow{Username: "user1", Password: "secret", Level: 50}, true},
+		{"level 51", "user1,secret,51", db.NewAccountRow{}, false},
+		{"negative level", "user1,secret,-1", db.NewAccountRow{}, false},
+		{"level not a number", "user1,secret,high", db.NewAccountRow{}, false},
+		{"too many fields", "user1,secret,30,extra", db.NewAccountRow{}, false},
+		{"missing password", "user1", db.NewAccountRow{}, false},
+		{"empty password", "user1:", db.NewAccountRow{}, false},
+		{"empty username", ",secret", db.NewAccountRow{}, false},
+		{"unterminated quote", `user1,"secret`, db.NewAccountRow{}, false},
+		{"username too long", "abcdefghijklmnopqrstuvwxyz1234567:secret", db.NewAccountRow{}, false},
+		{"password too long", "user1:abcdefghijklmnopqrstuvwxyz1234567", db.NewAccountRow{}, false},
+		{"multibyte username", strings.Repeat("ü", 32) + ":secret", db.NewAccountRow{Username: strings.Repeat("ü", 32), Password: "secret", Level: 5}, true},
+		{"multibyte username too long", strings.Repeat("ü", 33) + ":secret", db.NewAccountRow{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			account, err := parseAccountLine(test.line, 5)
+			if !test.valid {
+				if err == nil {
+					t.Errorf("parseAccountLine(%q) returned no error", test.line)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAccountLine(%q) returned error %s", test.line, err)
+			}
+			if account != test.account {
+				t.Errorf("parseAccountLine(%q) = %+v, want %+v", test.line, account, test.account)
+			}
+		})
+	}
+}
+
+func TestParseAccountLineWithoutDefaultLevel(t *testing.T) {
+	tests := []struct {
+		line  string
+		valid bool
+	}{
+		{"user1,secret,30", true},
+		{"user1,secret,0", true},
+		{"user1,secret", false},
+		{"user1,secret,", false},
+		{"user1:secret", false},
+	}
+
+	for _, test := range tests {
+		_, err := parseAccountLine(test.line, noDefaultLevel)
+		if test.valid && err != nil {
+			t.Errorf("parseAccountLine(%q) without default level returned error %s", test.line, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("parseAccountLine(%q) without default level returned no error", test.line)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package routes
 
 import (
+	"errors"
 	"flygon/accounts"
+	"flygon/config"
 	"flygon/db"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/exp/slices"
+	"gopkg.in/guregu/null.v4"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -16,12 +20,14 @@ type ApiAccountRecord struct {
 	Password                     string `json:"password"`
 	InUse                        bool   `json:"in_use"`
 	Level                        int    `json:"level"`
+	Group                        string `json:"group"`
 	Suspended                    bool   `json:"suspended"`
 	Banned                       bool   `json:"banned"`
 	Invalid                      bool   `json:"invalid"`
 	Warn                         bool   `json:"warn"`
 	Disabled                     bool   `json:"disabled"`
 	LastDisabled                 *int64 `json:"last_disabled"`
+	ConsecutiveDisabled          int    `json:"consecutive_disabled"`
 	LastBanned                   *int64 `json:"last_banned"`
 	LastSuspended                *int64 `json:"last_suspended"`
 	WarnMessageAcknowledged      bool   `json:"warn_message_acknowledged"`
@@ -30,18 +36,19 @@ type ApiAccountRecord struct {
 }
 
 func accountStatusToApiAccount(account accounts.AccountStatus) ApiAccountRecord {
-	time24HoursAgo := time.Now().Add(-24 * time.Hour).Unix()
 	return ApiAccountRecord{
 		Username:                     account.DbRow.Username,
 		Id:                           account.DbRow.Username,
 		Password:                     account.DbRow.Password,
 		InUse:                        account.InUse,
 		Level:                        account.DbRow.Level,
+		Group:                        account.DbRow.AccountGroup.ValueOrZero(),
 		Suspended:                    account.DbRow.Suspended,
 		Banned:                       account.DbRow.Banned,
 		Invalid:                      account.DbRow.Invalid,
 		Warn:                         account.DbRow.Warn,
-		Disabled:                     account.DbRow.LastDisabled.ValueOrZero() > time24HoursAgo,
+		Disabled:                     accounts.DisabledUntil(account.DbRow) > time.Now().Unix(),
+		ConsecutiveDisabled:          account.DbRow.ConsecutiveDisabled,
 		LastDisabled:                 account.DbRow.LastDisabled.Ptr(),
 		LastBanned:                   account.DbRow.LastBanned.Ptr(),
 		LastSuspended:                account.DbRow.LastSuspended.Ptr(),
@@ -100,7 +107,7 @@ func GetOneAccount(context *gin.Context) {
 }
 
 func GetAccountsStats(context *gin.Context) {
-	stats, statsErr := db.GetAccountsStats(*dbDetails)
+	stats, statsErr := db.GetAccountsStats(*dbDetails, config.Config.Tuning.DisableHours)
 
 	if statsErr != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"error": statsErr.Error()})
@@ -111,7 +118,7 @@ func GetAccountsStats(context *gin.Context) {
 }
 
 func GetLevelStats(context *gin.Context) {
-	stats, statsErr := db.GetLevelStats(*dbDetails)
+	stats, statsErr := db.GetLevelStats(*dbDetails, config.Config.Tuning.DisableHours)
 
 	if statsErr != nil {
 		context.JSON(http.StatusInternalServerError, gin.H{"error": statsErr.Error()})
@@ -129,6 +136,7 @@ type ApiSimpleAccountRecord struct {
 type ApiNewAccountBatch struct {
 	Accounts     []ApiSimpleAccountRecord `json:"accounts"`
 	DefaultLevel int                      `json:"default_level"`
+	Group        string                   `json:"group"`
 }
 
 func PostAccount(c *gin.Context) {
@@ -139,6 +147,11 @@ func PostAccount(c *gin.Context) {
 		c.Status(http.StatusInternalServerError)
 		return
 	}
+	group := strings.TrimSpace(requestBody.Group)
+	if err := validateAccountGroup(group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	var insertedNo int64 = 0
 
@@ -146,9 +159,10 @@ func PostAccount(c *gin.Context) {
 
 	for i, account := range requestBody.Accounts {
 		newAccounts[i] = db.NewAccountRow{
-			Username: account.Username,
-			Password: account.Password,
-			Level:    requestBody.DefaultLevel,
+			Username:     account.Username,
+			Password:     account.Password,
+			Level:        requestBody.DefaultLevel,
+			AccountGroup: null.NewString(group, group != ""),
 		}
 	}
 	res, err := db.InsertBulkAccounts(*dbDetails, newAccounts)
@@ -161,18 +175,100 @@ func PostAccount(c *gin.Context) {
 	c.JSON(http.StatusAccepted, gin.H{"updated": insertedNo})
 }
 
-type ApiDeleteAccountBatch struct {
+type ApiAccountGroup struct {
 	Usernames []string `json:"usernames"`
+	Group     string   `json:"group"`
+}
+
+// PutAccountGroup moves accounts into a group, an empty group removes them from their group
+func PutAccountGroup(c *gin.Context) {
+	var requestBody ApiAccountGroup
+
+	if err := c.BindJSON(&requestBody); err != nil {
+		log.Warnf("PUT /accounts/group Error during api %v", err)
+		return
+	}
+	group := strings.TrimSpace(requestBody.Group)
+	if err := validateAccountGroup(group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := accountManager.SetAccountGroup(requestBody.Usernames, group)
+	if err != nil {
+		log.Warnf("PUT /accounts/group Error during api %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"updated": updated})
+}
+
+// validateAccountGroup checks a group name fits the account_group column and the group lists of areas
+func validateAccountGroup(group string) error {
+	if len(group) > 64 {
+		return errors.New("group can't be longer than 64 characters")
+	}
+	if strings.Contains(group, ",") {
+		return errors.New("group can't contain ','")
+	}
+	return nil
+}
+
+type ApiDeleteAccountBatch struct {
+	Usernames []string                `json:"usernames"`
+	Filter    *ApiDeleteAccountFilter `json:"filter"`
+}
+
+// ApiDeleteAccountFilter selects accounts with any of the statuses, optionally only accounts not used
+// for the given number of days
+type ApiDeleteAccountFilter struct {
+	Banned        bool `json:"banned"`
+	Invalid       bool `json:"invalid"`
+	Suspended     bool `json:"suspended"`
+	OlderThanDays int  `json:"older_than_days"`
 }
 
+func (f ApiDeleteAccountFilter) matches(account db.Account) bool {
+	if !(f.Banned && account.Banned) && !(f.Invalid && account.Invalid) && !(f.Suspended && account.Suspended) {
+		return false
+	}
+	if f.OlderThanDays > 0 {
+		return account.LastSelected.ValueOrZero() < time.Now().AddDate(0, 0, -f.OlderThanDays).Unix()
+	}
+	return true
+}
+
+// DeleteAccount deletes the accounts given by username and the accounts matching the filter, accounts in use are kept
 func DeleteAccount(c *gin.Context) {
-	var requestBody ApiNewAccountBatch
+	var requestBody ApiDeleteAccountBatch
 
 	if err := c.BindJSON(&requestBody); err != nil {
-		// DO SOMETHING WITH THE ERROR
+		log.Warnf("DELETE /accounts/ Error during api %v", err)
+		return
 	}
 
-	c.JSON(http.StatusNotImplemented, gin.H{"message": "Accounts deletion not implemented yet"})
+	usernames := requestBody.Usernames
+	if filter := requestBody.Filter; filter != nil {
+		if !filter.Banned && !filter.Invalid && !filter.Suspended {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "filter needs at least one of banned, invalid or suspended"})
+			return
+		}
+		usernames = append(usernames, accountManager.FindAccounts(filter.matches)...)
+	}
+	if len(usernames) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no accounts to delete"})
+		return
+	}
+
+	deleted, inUse, err := accountManager.DeleteAccounts(usernames)
+	if err != nil {
+		log.Warnf("DELETE /accounts/ Error during api %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"deleted": deleted, "in_use": inUse})
 }
 
 type ApiPatchAccountBatch struct {
@@ -180,19 +276,57 @@ type ApiPatchAccountBatch struct {
 }
 
 type ApiPatchAccountSingle struct {
-	Username    string `json:"username"`
-	NewUsername string `json:"new_username"`
-	NewPassword string `json:"new_password"`
+	Username       string `json:"username"`
+	NewUsername    string `json:"new_username"`
+	NewPassword    string `json:"new_password"`
+	ClearBanned    bool   `json:"unban"`
+	ClearWarn      bool   `json:"clear_warn"`
+	ClearDisabled  bool   `json:"clear_disabled"`
+	ClearSuspended bool   `json:"clear_suspended"`
+	ClearInvalid   bool   `json:"clear_invalid"`
+}
+
+type ApiPatchAccountError struct {
+	Username string `json:"username"`
+	Error    string `json:"error"`
 }
 
+// PatchAccount changes usernames and passwords of accounts and resets their status. A new password
+// also clears the invalid status, as it was caused by the old credentials
 func PatchAccount(c *gin.Context) {
 	var requestBody ApiPatchAccountBatch
 
 	if err := c.BindJSON(&requestBody); err != nil {
-		// DO SOMETHING WITH THE ERROR
+		log.Warnf("PATCH /accounts/ Error during api %v", err)
+		return
+	}
+
+	updated := 0
+	patchErrors := []ApiPatchAccountError{}
+	for _, patch := range requestBody.Accounts {
+		if len(patch.NewUsername) > 32 || len(patch.NewPassword) > 32 {
+			patchErrors = append(patchErrors, ApiPatchAccountError{Username: patch.Username, Error: "username and password can't be longer than 32 characters"})
+			continue
+		}
+
+		err := accountManager.UpdateAccount(patch.Username, accounts.AccountUpdate{
+			NewUsername:    patch.NewUsername,
+			NewPassword:    patch.NewPassword,
+			ClearBanned:    patch.ClearBanned,
+			ClearWarn:      patch.ClearWarn,
+			ClearDisabled:  patch.ClearDisabled,
+			ClearSuspended: patch.ClearSuspended,
+			ClearInvalid:   patch.ClearInvalid || patch.NewPassword != "",
+		})
+		if err != nil {
+			log.Warnf("PATCH /accounts/ Error updating account %s: %v", patch.Username, err)
+			patchErrors = append(patchErrors, ApiPatchAccountError{Username: patch.Username, Error: err.Error()})
+			continue
+		}
+		updated++
 	}
 
-	c.JSON(http.StatusNotImplemented, gin.H{"message": "Accounts updates not implemented yet"})
+	c.JSON(http.StatusAccepted, gin.H{"updated": updated, "errors": patchErrors})
 }
 
 func GetReloadAccounts(c *gin.Context) {
@@ -19,13 +19,19 @@ type ApiLocation struct {
 }
 
 type ApiArea struct {
-	Name         string             `json:"name"`
-	PokemonMode  ApiAreaPokemonMode `json:"pokemon_mode"`
-	QuestMode    ApiAreaQuestMode   `json:"quest_mode"`
-	FortMode     ApiAreaFortMode    `json:"fort_mode"`
-	Geofence     []ApiLocation      `json:"geofence"`
-	EnableQuests bool               `json:"enable_quests"`
-	Id           int                `json:"id"`
+	Name           string             `json:"name"`
+	PokemonMode    ApiAreaPokemonMode `json:"pokemon_mode"`
+	QuestMode      ApiAreaQuestMode   `json:"quest_mode"`
+	FortMode       ApiAreaFortMode    `json:"fort_mode"`
+	Geofence       []ApiLocation      `json:"geofence"`
+	EnableQuests   bool               `json:"enable_quests"`
+	EnableLeveling bool               `json:"enable_leveling"`
+	Priority       int                `json:"priority"`
+	Weight         int                `json:"weight"`
+	Spillover      bool               `json:"spillover"`
+	RoutePartition string             `json:"route_partition"`
+	AccountGroups  []string           `json:"account_groups"`
+	Id             int                `json:"id"`
 }
 
 type ApiAreaPokemonMode struct {
@@ -184,11 +190,21 @@ func buildSingleArea(a db.Area, geometry bool) ApiArea {
 			Workers: a.FortModeWorkers,
 			Route:   CreateApiRoute(fortRoute),
 		},
-		Geofence:     CreateApiRoute(geofence),
-		EnableQuests: a.EnableQuests,
+		Geofence:       CreateApiRoute(geofence),
+		EnableQuests:   a.EnableQuests,
+		EnableLeveling: a.EnableLeveling,
+		Priority:       a.Priority,
+		Weight:         a.Weight,
+		Spillover:      a.Spillover,
+		RoutePartition: a.RoutePartition,
+		AccountGroups:  db.ParseAccountGroupsFromString(a.AccountGroups.ValueOrZero()),
 	}
 }
 
+func isValidRoutePartition(partition string) bool {
+	return partition == "" || partition == db.RoutePartitionIndex || partition == db.RoutePartitionCluster
+}
+
 func CreateAreaFromApiArea(requestBody ApiArea) *db.Area {
 	area := db.Area{}
 
@@ -202,6 +218,18 @@ func CreateAreaFromApiArea(requestBody ApiArea) *db.Area {
 	area.FortModeRoute = null.StringFrom(db.CreateRouteString(ApiRouteToLocation(requestBody.FortMode.Route)))
 	area.Geofence = null.StringFrom(db.CreateRouteString(ApiRouteToLocation(requestBody.Geofence)))
 	area.EnableQuests = requestBody.EnableQuests
+	area.EnableLeveling = requestBody.EnableLeveling
+	area.Priority = requestBody.Priority
+	area.Weight = requestBody.Weight
+	if area.Weight <= 0 {
+		area.Weight = 1
+	}
+	area.Spillover = requestBody.Spillover
+	area.RoutePartition = requestBody.RoutePartition
+	if area.RoutePartition == "" {
+		area.RoutePartition = db.RoutePartitionIndex
+	}
+	area.AccountGroups = null.StringFrom(db.CreateAccountGroupsString(requestBody.AccountGroups))
 
 	return &area
 }
@@ -214,6 +242,10 @@ func PostArea(c *gin.Context) {
 		c.Status(http.StatusInternalServerError)
 		return
 	}
+	if !isValidRoutePartition(requestBody.RoutePartition) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "route_partition has to be 'index' or 'cluster'"})
+		return
+	}
 
 	area := CreateAreaFromApiArea(requestBody)
 	id, err := db.CreateArea(*dbDetails, *area)
@@ -274,6 +306,10 @@ func PatchArea(c *gin.Context) {
 		log.Warnf("PATCH /areas/ Error during api %v", err)
 		return
 	}
+	if !isValidRoutePartition(requestBody.RoutePartition) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "route_partition has to be 'index' or 'cluster'"})
+		return
+	}
 
 	area := CreateAreaFromApiArea(requestBody)
 	area.Id = id
@@ -295,3 +331,69 @@ func PatchArea(c *gin.Context) {
 
 	worker.ReloadAreas(*dbDetails)
 }
+
+// defaultScanRadius is the radius in meters of a pokemon scan circle
+const defaultScanRadius = 70.0
+
+type ApiCalculateRoute struct {
+	Radius float64 `json:"radius"`
+}
+
+// PostCalculateRoute covers the geofence of the area with scan circles and stores the result as pokemon mode route
+func PostCalculateRoute(c *gin.Context) {
+	idParam := c.Param("area_id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	requestBody := ApiCalculateRoute{Radius: defaultScanRadius}
+	if c.Request.ContentLength > 0 {
+		if err := c.BindJSON(&requestBody); err != nil {
+			log.Warnf("POST /areas/%s/calculate-route Error during api %v", idParam, err)
+			return
+		}
+	}
+	if requestBody.Radius <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "radius has to be positive"})
+		return
+	}
+
+	dbArea, err := db.GetAreaRecord(*dbDetails, id)
+	if err != nil {
+		log.Warnf("POST /areas/%s/calculate-route Error during api %v", idParam, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if dbArea == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "area not found"})
+		return
+	}
+
+	fence, err := db.ParseRouteFromString(dbArea.Geofence.ValueOrZero())
+	if err != nil || len(fence) < 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "area has no valid geofence"})
+		return
+	}
+
+	route, err := geo.BuildRoute(geo.Geofence{Fence: fence}, requestBody.Radius)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Infof("API: Calculated route of area %d:%s with %d steps (radius %.0fm)", dbArea.Id, dbArea.Name, len(route), requestBody.Radius)
+
+	if _, err := db.SetPokemonModeRoute(*dbDetails, id, db.CreateRouteString(route)); err != nil {
+		log.Warnf("POST /areas/%s/calculate-route Error during api %v", idParam, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	dbArea, _ = db.GetAreaRecord(*dbDetails, id)
+	respArea := buildSingleArea(*dbArea, true)
+	c.JSON(http.StatusAccepted, &respArea)
+
+	worker.ReloadAreas(*dbDetails)
+}
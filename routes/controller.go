@@ -3,6 +3,7 @@ package routes
 import (
 	"flygon/accounts"
 	"flygon/config"
+	"flygon/db"
 	"flygon/external"
 	"flygon/worker"
 	"fmt"
@@ -134,15 +135,15 @@ func handleGetAccount(c *gin.Context, req ControllerBody, workerState *worker.St
 	var account = &accounts.AccountDetails{}
 	if workerState.Username != "" {
 		// reuse same account if possible -> to reuse auth token
-		if valid, err := accountManager.IsValidAccount(workerState.Username); err == nil && valid {
+		if valid, err := accountManager.IsValidAccount(workerState.Username); err == nil && valid && accountManager.AccountMatches(workerState.Username, accountSelector(workerState)) {
 			account = accountManager.GetAccount(workerState.Username)
 		} else {
 			accountManager.ReleaseAccount(workerState.Username)
-			account = accountManager.GetNextAccount(accounts.SelectLevel30)
+			account = accountManager.GetNextAccount(accountSelector(workerState))
 		}
 	} else {
 		accountManager.ReleaseAccount(workerState.Username)
-		account = accountManager.GetNextAccount(accounts.SelectLevel30)
+		account = accountManager.GetNextAccount(accountSelector(workerState))
 	}
 
 	if account == nil {
@@ -191,10 +192,13 @@ func handleGetJob(c *gin.Context, req ControllerBody, workerState *worker.State)
 		respondWithError(c, AccountNotFound)
 		return
 	}
-	if !isValid || workerState.Username != req.Username {
+	groupMatches := accountManager.AccountMatches(req.Username, accounts.SelectGroups(worker.AccountGroups(workerState.AreaId, workerState.Mode)))
+	if !isValid || workerState.Username != req.Username || !groupMatches {
 		var message string
 		if workerState.Username != req.Username {
 			message = fmt.Sprintf("is not equal to assigned worker account '%s'", workerState.Username)
+		} else if !groupMatches {
+			message = "is not in an account group of the worker's area and mode"
 		} else {
 			message = "is not valid"
 		}
@@ -202,15 +206,32 @@ func handleGetJob(c *gin.Context, req ControllerBody, workerState *worker.State)
 		workerState.ResetUsername()
 		accountManager.ReleaseAccount(req.Username)
 		workerState.ResetCounter()
+		minLevel, maxLevel := accountLevelRange(workerState.Mode)
 		respondWithData(c, &map[string]any{
 			"action":    SwitchAccount.String(),
-			"min_level": 30,
-			"max_level": 40,
+			"min_level": minLevel,
+			"max_level": maxLevel,
 		})
 		return
 	}
 
 	if workerState.AreaId == math.MaxInt32 {
+		if target, ok := worker.GetNextEncounterTarget(); ok {
+			task := map[string]any{
+				"action":        ScanIv.String(),
+				"lat":           target.Location.Latitude,
+				"lon":           target.Location.Longitude,
+				"id":            strconv.FormatUint(target.EncounterId, 10),
+				"is_spawnpoint": target.IsSpawnpoint,
+				"delay":         workerState.RemainingCooldown(target.Location),
+				"min_level":     30,
+				"max_level":     40,
+			}
+			log.Debugf("[CONTROLLER] [%s] Sending task %s at %f, %f", req.Uuid, task["action"], task["lat"], task["lon"])
+			workerState.Stats().RecordLocation()
+			respondWithData(c, &task)
+			return
+		}
 		task := map[string]any{
 			"action":    ScanPokemon.String(),
 			"lat":       0.0,
@@ -228,29 +249,105 @@ func handleGetJob(c *gin.Context, req ControllerBody, workerState *worker.State)
 		respondWithError(c, InstanceNotFound)
 		return
 	}
+	if workerState.Mode == worker.PokemonMode && wa.IsQuesting() {
+		layer := workerState.QuestLayer()
+		if step, location, ok := wa.GetNextQuestStep(layer, workerState.RemainingCooldown); ok {
+			workerState.QuestStep = step
+			questType := "ar"
+			if layer == worker.Quest_Layer_NoAr {
+				questType = "no_ar"
+			}
+			task := map[string]any{
+				"action":     ScanQuest.String(),
+				"lat":        location.Latitude,
+				"lon":        location.Longitude,
+				"delay":      workerState.RemainingCooldown(location),
+				"quest_type": questType,
+				"min_level":  30,
+				"max_level":  40,
+			}
+			log.Debugf("[CONTROLLER] [%s] Sending task %s at %f, %f (quest step %d %s, delay %d s)", req.Uuid, task["action"], task["lat"], task["lon"], step, questType, task["delay"])
+			workerState.Stats().RecordLocation()
+			respondWithData(c, &task)
+			return
+		}
+	}
 	if workerState.EndStep == 0 && workerState.StartStep == 0 {
 		// either the worker is new or was not working well
 		log.Debugf("[CONTROLLER] [%s] Recalculate route parts", workerState.Uuid)
 		wa.RecalculateRouteParts()
 	}
+	if workerState.Mode == worker.PokemonMode {
+		if step, location, ok := wa.GetRetryStep(workerState); ok {
+			task := map[string]any{
+				"action":    ScanPokemon.String(),
+				"lat":       location.Latitude,
+				"lon":       location.Longitude,
+				"min_level": 30,
+				"max_level": 40,
+			}
+			log.Debugf("[CONTROLLER] [%s] Sending task %s at %f, %f (retry of step %d)", req.Uuid, task["action"], task["lat"], task["lon"], step)
+			workerState.Stats().RecordLocation()
+			respondWithData(c, &task)
+			return
+		}
+	}
 	workerState.Step++
 	if workerState.Step > workerState.EndStep {
 		log.Infof("[CONTROLLER] [%s] Worker finished route", req.Uuid)
 		workerState.Step = workerState.StartStep
 	}
-	location := wa.GetRouteLocationOfStep(workerState.Step)
+	location, ok := wa.GetRouteLocationOfStep(workerState.Mode, workerState.Step)
+	if !ok {
+		log.Debugf("[CONTROLLER] [%s] No route step %d in %s route of area '%d'", req.Uuid, workerState.Step, workerState.Mode, workerState.AreaId)
+		respondWithError(c, NoTaskLeft)
+		return
+	}
+	if workerState.Mode == worker.PokemonMode {
+		wa.RecordStepDispatched(workerState, workerState.Step)
+		wa.LeaseStep(workerState, workerState.Step, location)
+	}
+	minLevel, maxLevel := accountLevelRange(workerState.Mode)
 	task := map[string]any{
 		"action":    ScanPokemon.String(),
 		"lat":       location.Latitude,
 		"lon":       location.Longitude,
-		"min_level": 30,
-		"max_level": 40,
+		"min_level": minLevel,
+		"max_level": maxLevel,
+	}
+	switch workerState.Mode {
+	case worker.FortMode:
+		task["action"] = ScanRaid.String()
+	case worker.LevelingMode:
+		task["action"] = SpinPokestop.String()
+		task["delay"] = workerState.RemainingCooldown(location)
 	}
 	log.Debugf("[CONTROLLER] [%s] Sending task %s at %f, %f", req.Uuid, task["action"], task["lat"], task["lon"])
+	workerState.Stats().RecordLocation()
 	respondWithData(c, &task)
 	return
 }
 
+// accountSelector returns the account filter used for a worker, leveling workers use accounts under level 30.
+// Only accounts of the groups allowed for the worker's area and mode are selected
+func accountSelector(workerState *worker.State) func(a db.Account) bool {
+	selectLevel := accounts.SelectLevel30
+	if workerState.Mode == worker.LevelingMode {
+		selectLevel = accounts.SelectUnderLevel30
+	}
+	selectGroups := accounts.SelectGroups(worker.AccountGroups(workerState.AreaId, workerState.Mode))
+	return func(a db.Account) bool {
+		return selectLevel(a) && selectGroups(a)
+	}
+}
+
+func accountLevelRange(mode worker.Mode) (int, int) {
+	if mode == worker.LevelingMode {
+		return 0, 29
+	}
+	return 30, 40
+}
+
 func handleTutorialDone(c *gin.Context, req ControllerBody, workerState *worker.State) {
 	log.Debugf("[CONTROLLER] [%s] TutorialDone from Account: %s", req.Uuid, req.Username)
 	if !accountManager.AccountExists(req.Username) {
@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"flygon/worker"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultStaleAfter is the default age in seconds after which a route step counts as stale
+const defaultStaleAfter = 600
+
+type ApiStepCoverage struct {
+	Step           int     `json:"step"`
+	Lat            float64 `json:"lat"`
+	Lon            float64 `json:"lon"`
+	LastDispatched int64   `json:"last_dispatched"`
+	LastScanned    int64   `json:"last_scanned"`
+}
+
+type ApiAreaCoverage struct {
+	AreaId         int               `json:"area_id"`
+	Workers        int               `json:"workers"`
+	RouteLength    int               `json:"route_length"`
+	CycleTime      int64             `json:"cycle_time"`
+	OldestScanAge  int64             `json:"oldest_scan_age"`
+	StaleAfter     int64             `json:"stale_after"`
+	StaleSteps     int               `json:"stale_steps"`
+	UnscannedSteps int               `json:"unscanned_steps"`
+	Steps          []ApiStepCoverage `json:"steps"`
+}
+
+// GetAreaCoverage returns when each pokemon route step of an area was handed out and scanned,
+// the stale time can be set with the query parameter stale_after in seconds
+func GetAreaCoverage(c *gin.Context) {
+	idParam := c.Param("area_id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		log.Warnf("GET /areas/%s/coverage Error during api %v", idParam, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if id == math.MaxInt32 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unbound enc can't be requested"})
+		return
+	}
+
+	staleAfter := int64(defaultStaleAfter)
+	if staleParam := c.Query("stale_after"); staleParam != "" {
+		staleAfter, err = strconv.ParseInt(staleParam, 10, 64)
+		if err != nil || staleAfter <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "stale_after has to be a positive number of seconds"})
+			return
+		}
+	}
+
+	wa := worker.GetWorkerArea(id)
+	if wa == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "area not found"})
+		return
+	}
+
+	coverage := wa.Coverage(staleAfter)
+	steps := make([]ApiStepCoverage, 0, len(coverage.Steps))
+	for _, s := range coverage.Steps {
+		steps = append(steps, ApiStepCoverage{
+			Step:           s.Step,
+			Lat:            s.Location.Latitude,
+			Lon:            s.Location.Longitude,
+			LastDispatched: s.LastDispatched * 1000,
+			LastScanned:    s.LastScanned * 1000,
+		})
+	}
+
+	c.JSON(http.StatusOK, ApiAreaCoverage{
+		AreaId:         id,
+		Workers:        worker.CountWorkersWithAreaAndMode(id, worker.PokemonMode),
+		RouteLength:    len(coverage.Steps),
+		CycleTime:      coverage.CycleTime,
+		OldestScanAge:  coverage.OldestScanAge,
+		StaleAfter:     staleAfter,
+		StaleSteps:     coverage.StaleSteps,
+		UnscannedSteps: coverage.UnscannedSteps,
+		Steps:          steps,
+	})
+}
@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"flygon/config"
+	"flygon/geo"
+	"flygon/worker"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+)
+
+type ApiEncounterTarget struct {
+	EncounterId     string  `json:"encounter_id"`
+	PokemonId       int32   `json:"pokemon_id"`
+	Weather         int32   `json:"weather"`
+	Latitude        float64 `json:"lat"`
+	Longitude       float64 `json:"lon"`
+	IsSpawnpoint    bool    `json:"is_spawnpoint"`
+	Priority        int     `json:"priority"`
+	ExpireTimestamp int64   `json:"expire_timestamp"`
+}
+
+type ApiEncounterTargetBatch struct {
+	Targets []ApiEncounterTarget `json:"targets"`
+}
+
+type golbatWebhook struct {
+	Type    string               `json:"type"`
+	Message golbatPokemonMessage `json:"message"`
+}
+
+type golbatPokemonMessage struct {
+	EncounterId      string  `json:"encounter_id"`
+	PokemonId        int32   `json:"pokemon_id"`
+	Latitude         float64 `json:"latitude"`
+	Longitude        float64 `json:"longitude"`
+	DisappearTime    int64   `json:"disappear_time"`
+	Weather          int32   `json:"weather"`
+	SpawnpointId     string  `json:"spawnpoint_id"`
+	IndividualAttack *int    `json:"individual_attack"`
+}
+
+func PostEncounterTargets(c *gin.Context) {
+	var requestBody ApiEncounterTargetBatch
+
+	if err := c.BindJSON(&requestBody); err != nil {
+		log.Warnf("POST /encounter-targets Error during post encounter targets %v", err)
+		return
+	}
+
+	queued := 0
+	for _, target := range requestBody.Targets {
+		encounterId, err := strconv.ParseUint(target.EncounterId, 10, 64)
+		if err != nil {
+			log.Warnf("POST /encounter-targets Invalid encounter id '%s'", target.EncounterId)
+			continue
+		}
+
+		expiry := time.Time{}
+		if target.ExpireTimestamp > 0 {
+			expiry = time.Unix(target.ExpireTimestamp, 0)
+		}
+
+		if worker.AddEncounterTarget(worker.EncounterTarget{
+			EncounterId:  encounterId,
+			PokemonId:    target.PokemonId,
+			WeatherBoost: target.Weather,
+			Location:     geo.Location{Latitude: target.Latitude, Longitude: target.Longitude},
+			IsSpawnpoint: target.IsSpawnpoint,
+			Priority:     target.Priority,
+			Expiry:       expiry,
+		}) {
+			queued++
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"queued": queued, "queue_length": worker.EncounterQueueLength()})
+}
+
+// PostGolbatWebhook receives pokemon webhooks from Golbat and queues pokemon without IV for the encounter workers
+func PostGolbatWebhook(c *gin.Context) {
+	var webhooks []golbatWebhook
+
+	if err := c.BindJSON(&webhooks); err != nil {
+		log.Warnf("POST /webhook/golbat Error during webhook %v", err)
+		return
+	}
+	c.Status(http.StatusOK)
+
+	priorityPokemon := config.Config.Worker.EncounterPriorityPokemon
+	for _, webhook := range webhooks {
+		if webhook.Type != "pokemon" || webhook.Message.IndividualAttack != nil {
+			continue
+		}
+		message := webhook.Message
+
+		encounterId, err := strconv.ParseUint(message.EncounterId, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		priority := 0
+		if slices.Contains(priorityPokemon, int(message.PokemonId)) {
+			priority = 1
+		}
+
+		expiry := time.Time{}
+		if message.DisappearTime > 0 {
+			expiry = time.Unix(message.DisappearTime, 0)
+		}
+
+		worker.AddEncounterTarget(worker.EncounterTarget{
+			EncounterId:  encounterId,
+			PokemonId:    message.PokemonId,
+			WeatherBoost: message.Weather,
+			Location:     geo.Location{Latitude: message.Latitude, Longitude: message.Longitude},
+			IsSpawnpoint: message.SpawnpointId != "" && message.SpawnpointId != "None",
+			Priority:     priority,
+			Expiry:       expiry,
+		})
+	}
+}
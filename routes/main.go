@@ -50,16 +50,33 @@ func StartGin() {
 	protectedApi.POST("/areas/", PostArea)
 	protectedApi.DELETE("/areas/:area_id", DeleteArea)
 	protectedApi.PATCH("/areas/:area_id", PatchArea)
+	protectedApi.GET("/areas/:area_id/schedules", GetAreaSchedules)
+	protectedApi.POST("/areas/:area_id/schedules", PostAreaSchedule)
+	protectedApi.DELETE("/areas/:area_id/schedules/:schedule_id", DeleteAreaSchedule)
+	protectedApi.GET("/areas/:area_id/coverage", GetAreaCoverage)
+	protectedApi.POST("/areas/:area_id/calculate-route", PostCalculateRoute)
+	protectedApi.GET("/areas/:area_id/quest-status", GetAreaQuestStatus)
 
 	protectedApi.GET("/workers/", GetWorkers)
+	protectedApi.GET("/workers/:uuid/assignment", GetWorkerAssignment)
+	protectedApi.GET("/workers/:uuid/stats", GetWorkerStats)
+	protectedApi.PUT("/workers/:uuid/assignment", PutWorkerAssignment)
+	protectedApi.DELETE("/workers/:uuid/assignment", DeleteWorkerAssignment)
+	protectedApi.GET("/assignments/", GetWorkerAssignments)
+
+	protectedApi.POST("/encounter-targets", PostEncounterTargets)
+	protectedApi.POST("/webhook/golbat", PostGolbatWebhook)
 
 	protectedApi.GET("/accounts/", GetAccounts)
 	protectedApi.GET("/accounts/stats", GetAccountsStats)
 	protectedApi.GET("/accounts/level-stats", GetLevelStats)
+	protectedApi.GET("/accounts/export", GetAccountExport)
 	protectedApi.GET("/accounts/:account_name", GetOneAccount)
 	protectedApi.POST("/accounts/", PostAccount)
+	protectedApi.POST("/accounts/import", PostAccountImport)
 	protectedApi.DELETE("/accounts/", DeleteAccount)
 	protectedApi.PATCH("/accounts/", PatchAccount)
+	protectedApi.PUT("/accounts/group", PutAccountGroup)
 	protectedApi.GET("/reload/accounts", GetReloadAccounts)
 
 	protectedApi.GET("/reload", GetReload)
@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"flygon/worker"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+type ApiQuestLayerStatus struct {
+	Pass     int  `json:"pass"`
+	Step     int  `json:"step"`
+	Steps    int  `json:"steps"`
+	Finished bool `json:"finished"`
+}
+
+type ApiQuestStatus struct {
+	AreaId    int                 `json:"area_id"`
+	Questing  bool                `json:"questing"`
+	Ar        ApiQuestLayerStatus `json:"ar"`
+	NoAr      ApiQuestLayerStatus `json:"no_ar"`
+	Quests    uint32              `json:"quests"`
+	AltQuests uint32              `json:"alt_quests"`
+	Total     uint32              `json:"total"`
+	Updated   int64               `json:"updated"`
+}
+
+func buildQuestLayerStatus(l worker.QuestLayerProgress) ApiQuestLayerStatus {
+	return ApiQuestLayerStatus{
+		Pass:     l.Pass,
+		Step:     l.Step,
+		Steps:    l.Steps,
+		Finished: l.Finished,
+	}
+}
+
+// GetAreaQuestStatus returns the progress of the quest run of an area and the quest status reported by Golbat
+func GetAreaQuestStatus(c *gin.Context) {
+	idParam := c.Param("area_id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		log.Warnf("GET /areas/%s/quest-status Error during api %v", idParam, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if id == math.MaxInt32 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unbound enc can't be requested"})
+		return
+	}
+
+	wa := worker.GetWorkerArea(id)
+	if wa == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "area not found"})
+		return
+	}
+
+	progress := wa.QuestProgress()
+	updated := int64(0)
+	if !progress.StatusTime.IsZero() {
+		updated = progress.StatusTime.UnixMilli()
+	}
+	c.JSON(http.StatusOK, ApiQuestStatus{
+		AreaId:    id,
+		Questing:  progress.Questing,
+		Ar:        buildQuestLayerStatus(progress.Layers[worker.Quest_Layer_AR]),
+		NoAr:      buildQuestLayerStatus(progress.Layers[worker.Quest_Layer_NoAr]),
+		Quests:    progress.Status.Quests,
+		AltQuests: progress.Status.AltQuests,
+		Total:     progress.Status.TotalStops,
+		Updated:   updated,
+	})
+}
@@ -6,11 +6,13 @@ import (
 	b64 "encoding/base64"
 	"encoding/json"
 	"flygon/external"
+	"flygon/geo"
 	"flygon/pogo"
 	"flygon/worker"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/proto"
+	"math"
 	"net"
 	"net/http"
 	"time"
@@ -83,17 +85,44 @@ func Raw(c *gin.Context) {
 
 		host := c.RemoteIP()
 		ws := worker.GetWorkerState(res.Uuid)
-		ws.LastLocation(0.0, 0.0, host) //TODO we need the last location for cooldown
+		ws.LastLocation(res.LatTarget, res.LonTarget, host)
+		if res.HaveAr != nil {
+			ws.SetHaveAr(*res.HaveAr)
+		}
 		if res.TrainerLvl > 0 {
 			accountManager.SetLevel(res.Username, res.TrainerLvl)
+			if res.TrainerLvl >= 30 && ws.Mode == worker.LevelingMode {
+				if ws.PromoteFromLeveling() {
+					log.Infof("[RAW] [%s] Account '%s' reached level %d, worker moved to area %d", res.Uuid, res.Username, res.TrainerLvl, ws.AreaId)
+				} else {
+					log.Infof("[RAW] [%s] Account '%s' reached level %d, no area needs workers - switching account", res.Uuid, res.Username, res.TrainerLvl)
+					ws.ResetUsername()
+				}
+			}
 		}
 		//body, _ := ioutil.ReadAll(c.Request.Body)
 
 		for _, rawContent := range res.Contents {
 			if rawContent.Method == int(pogo.Method_METHOD_GET_MAP_OBJECTS) {
 				ws.IncrementLimit(int(pogo.Method_METHOD_GET_MAP_OBJECTS))
+				acknowledgeScan(ws, res)
+				if recordMapObjects(ws, rawContent) {
+					accountManager.MarkSessionWorked(res.Username)
+				}
 			} else if rawContent.Method == int(pogo.Method_METHOD_ENCOUNTER) {
 				ws.IncrementLimit(int(pogo.Method_METHOD_ENCOUNTER))
+				ws.RecordInteraction()
+				ws.Stats().RecordEncounter()
+			} else if rawContent.Method == int(pogo.Method_METHOD_FORT_SEARCH) {
+				ws.RecordInteraction()
+				if rawContent.HaveAr != nil {
+					ws.SetHaveAr(*rawContent.HaveAr)
+				}
+				fortSearch := decodeFortSearchOutProto(rawContent)
+				if fortSearch != nil {
+					ws.Stats().RecordFortSearch(fortSearch.ChallengeQuest != nil)
+					recordQuestScan(ws, res, rawContent, fortSearch)
+				}
 			} else if rawContent.Method == int(pogo.Method_METHOD_GET_PLAYER) {
 				getPlayerOutProto := decodeGetPlayerOutProto(rawContent)
 				accountManager.UpdateDetailsFromGame(res.Username, getPlayerOutProto, res.TrainerLvl)
@@ -144,6 +173,73 @@ func rawSender(url string, password string, c *gin.Context, data rawBody) {
 	_ = resp.Body.Close()
 }
 
+func recordQuestScan(ws *worker.State, res rawBody, rawContent content, fortSearch *pogo.FortSearchOutProto) {
+	wa := worker.GetWorkerArea(ws.AreaId)
+	if wa == nil || !wa.IsQuesting() {
+		return
+	}
+	if fortSearch.FortId == "" {
+		return
+	}
+	haveAr := false
+	if rawContent.HaveAr != nil {
+		haveAr = *rawContent.HaveAr
+	} else if res.HaveAr != nil {
+		haveAr = *res.HaveAr
+	}
+	wa.RecordQuestScan(fortSearch.FortId, haveAr, ws.Uuid, ws.QuestStep, fortSearch.ChallengeQuest != nil)
+	log.Debugf("[RAW] [%s] Quest scan of pokestop %s recorded (AR: %t)", res.Uuid, fortSearch.FortId, haveAr)
+}
+
+// recordMapObjects counts a received GMO and the pokemon seen in it for the worker stats, returns true if the GMO
+// contained map cells
+func recordMapObjects(ws *worker.State, rawContent content) bool {
+	gmo := decodeGetMapObjectsOutProto(rawContent)
+	if gmo == nil {
+		return false
+	}
+	monsSeen := 0
+	for _, mapCell := range gmo.MapCell {
+		monsSeen += len(mapCell.WildPokemon) + len(mapCell.NearbyPokemon)
+	}
+	ws.Stats().RecordMapObjects(monsSeen)
+	return len(gmo.MapCell) > 0
+}
+
+func acknowledgeScan(ws *worker.State, res rawBody) {
+	if ws.Mode != worker.PokemonMode || ws.AreaId == 0 || ws.AreaId == math.MaxInt32 {
+		return
+	}
+	if res.LatTarget == 0 && res.LonTarget == 0 {
+		return
+	}
+	wa := worker.GetWorkerArea(ws.AreaId)
+	if wa == nil {
+		return
+	}
+	wa.AcknowledgeScan(ws, geo.Location{Latitude: res.LatTarget, Longitude: res.LonTarget})
+}
+
+func decodeFortSearchOutProto(content content) *pogo.FortSearchOutProto {
+	fortSearchProto := &pogo.FortSearchOutProto{}
+	data, _ := b64.StdEncoding.DecodeString(content.Data)
+	if err := proto.Unmarshal(data, fortSearchProto); err != nil {
+		log.Warnf("Failed to parse FortSearchOutProto: %s", err)
+		return nil
+	}
+	return fortSearchProto
+}
+
+func decodeGetMapObjectsOutProto(content content) *pogo.GetMapObjectsOutProto {
+	gmoProto := &pogo.GetMapObjectsOutProto{}
+	data, _ := b64.StdEncoding.DecodeString(content.Data)
+	if err := proto.Unmarshal(data, gmoProto); err != nil {
+		log.Warnf("Failed to parse GetMapObjectsOutProto: %s", err)
+		return nil
+	}
+	return gmoProto
+}
+
 func decodeGetPlayerOutProto(content content) *pogo.GetPlayerOutProto {
 	getPlayerProto := &pogo.GetPlayerOutProto{}
 	data, _ := b64.StdEncoding.DecodeString(content.Data)
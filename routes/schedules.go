@@ -0,0 +1,138 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"flygon/db"
+	"flygon/util"
+	"flygon/worker"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+type ApiAreaSchedule struct {
+	Id                 int    `json:"id"`
+	AreaId             int    `json:"area_id"`
+	Cron               string `json:"cron"`
+	PokemonModeWorkers int    `json:"pokemon_mode_workers"`
+	FortModeWorkers    int    `json:"fort_mode_workers"`
+	QuestMode          bool   `json:"quest_mode"`
+}
+
+func buildSingleSchedule(s db.AreaSchedule) ApiAreaSchedule {
+	return ApiAreaSchedule{
+		Id:                 s.Id,
+		AreaId:             s.AreaId,
+		Cron:               s.Cron,
+		PokemonModeWorkers: s.PokemonModeWorkers,
+		FortModeWorkers:    s.FortModeWorkers,
+		QuestMode:          s.QuestMode,
+	}
+}
+
+func GetAreaSchedules(c *gin.Context) {
+	idParam := c.Param("area_id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedules, err := db.GetAreaScheduleRecordsForArea(*dbDetails, id)
+	if err != nil {
+		log.Warnf("GET /areas/%s/schedules Error during api %v", idParam, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	scheduleList := []ApiAreaSchedule{}
+	for _, s := range schedules {
+		scheduleList = append(scheduleList, buildSingleSchedule(s))
+	}
+
+	paginateAndSort(c, scheduleList)
+}
+
+func PostAreaSchedule(c *gin.Context) {
+	idParam := c.Param("area_id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var requestBody ApiAreaSchedule
+	if err := c.BindJSON(&requestBody); err != nil {
+		log.Warnf("POST /areas/%s/schedules Error during api %v", idParam, err)
+		return
+	}
+
+	if _, err := util.ParseCron(requestBody.Cron); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dbArea, err := db.GetAreaRecord(*dbDetails, id)
+	if err != nil {
+		log.Warnf("POST /areas/%s/schedules Error during api %v", idParam, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if dbArea == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "area not found"})
+		return
+	}
+
+	schedule := db.AreaSchedule{
+		AreaId:             id,
+		Cron:               requestBody.Cron,
+		PokemonModeWorkers: requestBody.PokemonModeWorkers,
+		FortModeWorkers:    requestBody.FortModeWorkers,
+		QuestMode:          requestBody.QuestMode,
+	}
+	scheduleId, err := db.CreateAreaSchedule(*dbDetails, schedule)
+	if err != nil {
+		log.Warnf("POST /areas/%s/schedules Error during api %v", idParam, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	schedule.Id = int(scheduleId)
+
+	c.JSON(http.StatusAccepted, buildSingleSchedule(schedule))
+
+	worker.ReloadAreas(*dbDetails)
+}
+
+func DeleteAreaSchedule(c *gin.Context) {
+	idParam := c.Param("area_id")
+	scheduleIdParam := c.Param("schedule_id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	scheduleId, err := strconv.Atoi(scheduleIdParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, err := db.DeleteAreaSchedule(*dbDetails, id, scheduleId)
+	if err != nil {
+		log.Warnf("DELETE /areas/%s/schedules/%s Error during api %v", idParam, scheduleIdParam, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+
+	worker.ReloadAreas(*dbDetails)
+}
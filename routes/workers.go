@@ -1,8 +1,15 @@
 package routes
 
 import (
+	"flygon/db"
 	"flygon/worker"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
 )
 
 type ApiWorkerState struct {
@@ -10,6 +17,7 @@ type ApiWorkerState struct {
 	Uuid      string `json:"uuid"`
 	Username  string `json:"username"`
 	AreaId    int    `json:"area_id"`
+	Mode      string `json:"mode"`
 	StartStep int    `json:"start_step"`
 	EndStep   int    `json:"end_step"`
 	Step      int    `json:"step"`
@@ -39,6 +47,7 @@ func buildSingleWorker(s *worker.State) ApiWorkerState {
 		Uuid:      s.Uuid,
 		Username:  s.Username,
 		AreaId:    s.AreaId,
+		Mode:      s.Mode.String(),
 		StartStep: s.StartStep,
 		EndStep:   s.EndStep,
 		Step:      s.Step,
@@ -46,3 +55,174 @@ func buildSingleWorker(s *worker.State) ApiWorkerState {
 		LastSeen:  s.LastSeen * 1000,
 	}
 }
+
+type ApiWorkerAssignment struct {
+	Uuid   string `json:"uuid"`
+	AreaId int    `json:"area_id"`
+}
+
+func GetWorkerAssignments(c *gin.Context) {
+	assignments, err := db.GetWorkerAssignmentRecords(*dbDetails)
+	if err != nil {
+		log.Warnf("GET /assignments/ Error during api %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	assignmentList := []ApiWorkerAssignment{}
+	for _, a := range assignments {
+		assignmentList = append(assignmentList, ApiWorkerAssignment{
+			Uuid:   a.Uuid,
+			AreaId: a.AreaId,
+		})
+	}
+
+	paginateAndSort(c, assignmentList)
+}
+
+// GetWorkerAssignment returns the area a worker uuid is pinned to, including wildcard matches
+func GetWorkerAssignment(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	areaId, pinned := worker.GetAssignedAreaId(uuid)
+	if !pinned {
+		c.JSON(http.StatusNotFound, gin.H{"error": "worker is not assigned"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ApiWorkerAssignment{
+		Uuid:   uuid,
+		AreaId: areaId,
+	})
+}
+
+// PutWorkerAssignment pins a worker uuid or uuid pattern (e.g. "phone-*") to an area
+func PutWorkerAssignment(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	var requestBody ApiWorkerAssignment
+	if err := c.BindJSON(&requestBody); err != nil {
+		log.Warnf("PUT /workers/%s/assignment Error during api %v", uuid, err)
+		return
+	}
+
+	if _, err := path.Match(uuid, ""); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid uuid pattern"})
+		return
+	}
+	if area, err := db.GetAreaRecord(*dbDetails, requestBody.AreaId); err != nil || area == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "area not found"})
+		return
+	}
+
+	assignment := db.WorkerAssignment{
+		Uuid:   uuid,
+		AreaId: requestBody.AreaId,
+	}
+	if err := db.SetWorkerAssignment(*dbDetails, assignment); err != nil {
+		log.Warnf("PUT /workers/%s/assignment Error during api %v", uuid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	worker.LoadWorkerAssignments(*dbDetails)
+	worker.ApplyWorkerAssignments()
+
+	c.JSON(http.StatusAccepted, ApiWorkerAssignment{
+		Uuid:   uuid,
+		AreaId: requestBody.AreaId,
+	})
+}
+
+func DeleteWorkerAssignment(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	rows, err := db.DeleteWorkerAssignment(*dbDetails, uuid)
+	if err != nil {
+		log.Warnf("DELETE /workers/%s/assignment Error during api %v", uuid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "assignment not found"})
+		return
+	}
+
+	worker.LoadWorkerAssignments(*dbDetails)
+
+	c.Status(http.StatusAccepted)
+}
+
+// defaultStatsRange is the time range in seconds returned by worker stats without from parameter
+const defaultStatsRange = 24 * 60 * 60
+
+type ApiWorkerStats struct {
+	Datetime   int64   `json:"datetime"`
+	DeviceName string  `json:"device_name"`
+	LocAvg     float64 `json:"loc_avg"`
+	LocCount   int64   `json:"loc_count"`
+	LocSuccess int64   `json:"loc_success"`
+	MonsSeen   int64   `json:"mons_seen"`
+	MonsEnc    int64   `json:"mons_enc"`
+	Stops      int64   `json:"stops"`
+	Quests     int64   `json:"quests"`
+}
+
+// GetWorkerStats returns the stats of a worker, the time range can be set with the query parameters
+// from and to as timestamps in milliseconds and defaults to the last day
+func GetWorkerStats(c *gin.Context) {
+	uuid := c.Param("uuid")
+
+	now := time.Now().Unix()
+	from, err := timestampQuery(c, "from", now-defaultStatsRange)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := timestampQuery(c, "to", now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if from > to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from has to be before to"})
+		return
+	}
+
+	records, err := db.GetWorkerStatsRecords(*dbDetails, uuid, from, to)
+	if err != nil {
+		log.Warnf("GET /workers/%s/stats Error during api %v", uuid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats := []ApiWorkerStats{}
+	for _, r := range records {
+		stats = append(stats, ApiWorkerStats{
+			Datetime:   r.Datetime * 1000,
+			DeviceName: r.DeviceName.ValueOrZero(),
+			LocAvg:     r.LocAvg.ValueOrZero(),
+			LocCount:   r.LocCount.ValueOrZero(),
+			LocSuccess: r.LocSuccess.ValueOrZero(),
+			MonsSeen:   r.MonsSeen.ValueOrZero(),
+			MonsEnc:    r.MonsEnc.ValueOrZero(),
+			Stops:      r.Stops.ValueOrZero(),
+			Quests:     r.Quests.ValueOrZero(),
+		})
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// timestampQuery returns the query parameter as unix timestamp, the parameter is given in milliseconds
+func timestampQuery(c *gin.Context, param string, defaultValue int64) (int64, error) {
+	queryParam := c.Query(param)
+	if queryParam == "" {
+		return defaultValue, nil
+	}
+	ms, err := strconv.ParseInt(queryParam, 10, 64)
+	if err != nil || ms < 0 {
+		return 0, fmt.Errorf("%s has to be a timestamp in milliseconds", param)
+	}
+	return ms / 1000, nil
+}
@@ -0,0 +1,2 @@
+ALTER TABLE `area`
+    ADD COLUMN `enable_leveling` tinyint(1) NOT NULL DEFAULT 0 AFTER `enable_quests`;
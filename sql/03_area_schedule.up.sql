@@ -0,0 +1,12 @@
+CREATE TABLE `area_schedule`
+(
+    `id`                   int(10) unsigned NOT NULL AUTO_INCREMENT,
+    `area_id`              int(10) unsigned NOT NULL,
+    `cron`                 varchar(64) NOT NULL,
+    `pokemon_mode_workers` int(10) unsigned NOT NULL DEFAULT 0,
+    `fort_mode_workers`    int(10) unsigned NOT NULL DEFAULT 0,
+    `quest_mode`           tinyint(1) NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `area_id` (`area_id`),
+    CONSTRAINT `area_schedule_area_id` FOREIGN KEY (`area_id`) REFERENCES `area` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+ALTER TABLE `area`
+    ADD COLUMN `priority`  int(11) NOT NULL DEFAULT 0 AFTER `enable_leveling`,
+    ADD COLUMN `weight`    int(10) unsigned NOT NULL DEFAULT 1 AFTER `priority`,
+    ADD COLUMN `spillover` tinyint(1) NOT NULL DEFAULT 0 AFTER `weight`;
@@ -0,0 +1,8 @@
+CREATE TABLE `worker_assignment`
+(
+    `uuid`    varchar(255) NOT NULL,
+    `area_id` int(10) unsigned NOT NULL,
+    PRIMARY KEY (`uuid`),
+    KEY `area_id` (`area_id`),
+    CONSTRAINT `worker_assignment_area_id` FOREIGN KEY (`area_id`) REFERENCES `area` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+CREATE TABLE `worker`
+(
+    `uuid`       varchar(255) NOT NULL,
+    `area_id`    int(10) unsigned NOT NULL DEFAULT 0,
+    `mode`       tinyint(3) unsigned NOT NULL DEFAULT 0,
+    `username`   varchar(255) DEFAULT NULL,
+    `start_step` int(10) unsigned NOT NULL DEFAULT 0,
+    `end_step`   int(10) unsigned NOT NULL DEFAULT 0,
+    `step`       int(10) unsigned NOT NULL DEFAULT 0,
+    `host`       varchar(255) NOT NULL DEFAULT '',
+    `last_seen`  int(10) unsigned NOT NULL DEFAULT 0,
+    PRIMARY KEY (`uuid`)
+);
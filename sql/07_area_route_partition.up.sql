@@ -0,0 +1,2 @@
+ALTER TABLE `area`
+    ADD COLUMN `route_partition` varchar(20) NOT NULL DEFAULT 'index' AFTER `spillover`;
@@ -0,0 +1,6 @@
+ALTER TABLE `account`
+    ADD COLUMN `account_group` varchar(64) DEFAULT NULL AFTER `level`,
+    ADD KEY `account_group` (`account_group`);
+
+ALTER TABLE `area`
+    ADD COLUMN `account_groups` varchar(255) DEFAULT NULL AFTER `route_partition`;
@@ -0,0 +1,2 @@
+ALTER TABLE `account`
+    ADD COLUMN `consecutive_disabled` int(10) unsigned NOT NULL DEFAULT 0 AFTER `last_disabled`;
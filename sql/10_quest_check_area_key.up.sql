@@ -0,0 +1,7 @@
+ALTER TABLE `quest_check`
+    DROP FOREIGN KEY `quest_check_ix`;
+
+ALTER TABLE `quest_check`
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (`area_id`, `lat`, `lon`),
+    ADD CONSTRAINT `quest_check_ix` FOREIGN KEY (`area_id`) REFERENCES `area` (`id`) ON DELETE CASCADE;
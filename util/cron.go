@@ -0,0 +1,198 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed five field cron expression (minute hour day-of-month month day-of-week)
+type CronSchedule struct {
+	minutes    uint64
+	hours      uint64
+	days       uint64
+	months     uint64
+	weekdays   uint64
+	anyDay     bool
+	anyWeekday bool
+}
+
+// maxCronLookbackDays limits how far back the last start of a schedule is searched, long enough to reach
+// the last 29th of february
+const maxCronLookbackDays = 8 * 366
+
+func ParseCron(expression string) (*CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.New("cron expression needs 5 fields: minute hour day month weekday")
+	}
+
+	var schedule CronSchedule
+	var err error
+	if schedule.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if schedule.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if schedule.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("day: %w", err)
+	}
+	if schedule.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	if schedule.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("weekday: %w", err)
+	}
+	// both 0 and 7 are sunday
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+	schedule.anyDay = fields[2] == "*"
+	schedule.anyWeekday = fields[4] == "*"
+
+	if !schedule.canMatch() {
+		return nil, errors.New("day never matches month")
+	}
+
+	return &schedule, nil
+}
+
+// parseCronField parses lists, ranges and steps like "1,5-10,*/15" into a bit set
+func parseCronField(field string, min int, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangeAndStep := strings.SplitN(part, "/", 2); len(rangeAndStep) == 2 {
+			var err error
+			if step, err = strconv.Atoi(rangeAndStep[1]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in '%s'", part)
+			}
+			part = rangeAndStep[0]
+		}
+
+		start, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value '%s'", part)
+			}
+			end = start
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value '%s'", part)
+				}
+			}
+		}
+		if start < min || end > max || start > end {
+			return 0, fmt.Errorf("value '%s' out of range %d-%d", part, min, max)
+		}
+
+		for x := start; x <= end; x += step {
+			bits |= 1 << uint(x)
+		}
+	}
+	return bits, nil
+}
+
+// Matches returns true if the schedule starts in the minute of the given time
+func (c *CronSchedule) Matches(t time.Time) bool {
+	if c.minutes&(1<<uint(t.Minute())) == 0 || c.hours&(1<<uint(t.Hour())) == 0 {
+		return false
+	}
+	year, month, day := t.Date()
+	return c.dateMatches(year, month, day)
+}
+
+func (c *CronSchedule) dateMatches(year int, month time.Month, day int) bool {
+	if c.months&(1<<uint(month)) == 0 {
+		return false
+	}
+
+	dayMatches := c.days&(1<<uint(day)) != 0
+	weekday := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday()
+	weekdayMatches := c.weekdays&(1<<uint(weekday)) != 0
+	// like cron, a restricted day and weekday match if either of them matches
+	if !c.anyDay && !c.anyWeekday {
+		return dayMatches || weekdayMatches
+	}
+	return dayMatches && weekdayMatches
+}
+
+// canMatch returns false if the days only exist in months which are not selected, like "0 0 30 2 *"
+func (c *CronSchedule) canMatch() bool {
+	if !c.anyWeekday {
+		// every weekday occurs in every month
+		return true
+	}
+	for month := time.January; month <= time.December; month++ {
+		if c.months&(1<<uint(month)) == 0 {
+			continue
+		}
+		// 2024 is a leap year, so the 29th of february counts
+		for day := 1; day <= daysInMonth(2024, month); day++ {
+			if c.days&(1<<uint(day)) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Previous returns the last start of the schedule at or before the given time, false if there was none in the lookback.
+// Days are walked backwards and the latest hour and minute of the first matching day is taken
+func (c *CronSchedule) Previous(t time.Time) (time.Time, bool) {
+	year, month, day := t.Date()
+	hour, minute := t.Hour(), t.Minute()
+	for x := 0; x < maxCronLookbackDays; x++ {
+		if c.months&(1<<uint(month)) == 0 {
+			// continue with the last day of the previous month
+			day = 1
+		} else if c.dateMatches(year, month, day) {
+			if h, m, ok := c.previousTimeOfDay(hour, minute); ok {
+				// times skipped by a daylight saving change are moved forward by time.Date and may be after t
+				if start := time.Date(year, month, day, h, m, 0, 0, t.Location()); !start.After(t) {
+					return start, true
+				}
+			}
+		}
+
+		day--
+		if day == 0 {
+			month--
+			if month == 0 {
+				month = time.December
+				year--
+			}
+			day = daysInMonth(year, month)
+		}
+		hour, minute = 23, 59
+	}
+	return time.Time{}, false
+}
+
+// previousTimeOfDay returns the latest hour and minute of the schedule at or before the given hour and minute
+func (c *CronSchedule) previousTimeOfDay(hour int, minute int) (int, int, bool) {
+	for h := hour; h >= 0; h-- {
+		if c.hours&(1<<uint(h)) == 0 {
+			continue
+		}
+		limit := 59
+		if h == hour {
+			limit = minute
+		}
+		for m := limit; m >= 0; m-- {
+			if c.minutes&(1<<uint(m)) != 0 {
+				return h, m, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,162 @@
+package util
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		valid      bool
+	}{
+		{"every minute", "* * * * *", true},
+		{"lists ranges and steps", "0,30 8-18/2 1-15 */3 1-5", true},
+		{"sunday as 7", "0 0 * * 7", true},
+		{"29th of february", "0 0 29 2 *", true},
+		{"30th of february with weekday", "0 0 30 2 1", true},
+		{"too few fields", "* * * *", false},
+		{"too many fields", "* * * * * *", false},
+		{"minute out of range", "60 * * * *", false},
+		{"day out of range", "0 0 0 * *", false},
+		{"reversed range", "0 10-5 * * *", false},
+		{"zero step", "*/0 * * * *", false},
+		{"invalid value", "a * * * *", false},
+		{"30th of february", "0 0 30 2 *", false},
+		{"31st of april and june", "0 0 31 4,6 *", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseCron(test.expression)
+			if test.valid && err != nil {
+				t.Errorf("ParseCron(%q) returned error %s", test.expression, err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("ParseCron(%q) returned no error", test.expression)
+			}
+		})
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		time       time.Time
+		matches    bool
+	}{
+		{"range start", "0 8-18 * * *", date(2023, 5, 10, 8, 0), true},
+		{"range end", "0 8-18 * * *", date(2023, 5, 10, 18, 0), true},
+		{"outside range", "0 8-18 * * *", date(2023, 5, 10, 19, 0), false},
+		{"step hit", "*/15 * * * *", date(2023, 5, 10, 8, 45), true},
+		{"step miss", "*/15 * * * *", date(2023, 5, 10, 8, 50), false},
+		{"range with step", "10-40/10 * * * *", date(2023, 5, 10, 8, 30), true},
+		{"range with step miss", "10-40/10 * * * *", date(2023, 5, 10, 8, 50), false},
+		{"list hit", "5,25 * * * *", date(2023, 5, 10, 8, 25), true},
+		{"list miss", "5,25 * * * *", date(2023, 5, 10, 8, 15), false},
+		{"month miss", "0 0 * 6 *", date(2023, 5, 10, 0, 0), false},
+		// 2023-05-14 is a sunday
+		{"sunday as 0", "0 0 * * 0", date(2023, 5, 14, 0, 0), true},
+		{"sunday as 7", "0 0 * * 7", date(2023, 5, 14, 0, 0), true},
+		{"weekday only", "0 0 * * 1", date(2023, 5, 14, 0, 0), false},
+		{"day only", "0 0 14 * *", date(2023, 5, 14, 0, 0), true},
+		// a restricted day and weekday match if either of them matches
+		{"day or weekday by day", "0 0 14 * 1", date(2023, 5, 14, 0, 0), true},
+		{"day or weekday by weekday", "0 0 1 * 0", date(2023, 5, 14, 0, 0), true},
+		{"day or weekday none", "0 0 1 * 1", date(2023, 5, 14, 0, 0), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ParseCron(test.expression)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) returned error %s", test.expression, err)
+			}
+			if matches := schedule.Matches(test.time); matches != test.matches {
+				t.Errorf("Matches(%s) of %q = %t, want %t", test.time, test.expression, matches, test.matches)
+			}
+		})
+	}
+}
+
+func TestCronPrevious(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		time       time.Time
+		previous   time.Time
+	}{
+		{"same minute", "30 8 * * *", date(2023, 5, 10, 8, 30), date(2023, 5, 10, 8, 30)},
+		{"seconds are ignored", "30 8 * * *", date(2023, 5, 10, 8, 30).Add(45 * time.Second), date(2023, 5, 10, 8, 30)},
+		{"earlier today", "30 8 * * *", date(2023, 5, 10, 12, 0), date(2023, 5, 10, 8, 30)},
+		{"yesterday", "30 8 * * *", date(2023, 5, 10, 8, 29), date(2023, 5, 9, 8, 30)},
+		{"latest minute of hour", "*/20 * * * *", date(2023, 5, 10, 8, 59), date(2023, 5, 10, 8, 40)},
+		{"previous hour", "50 * * * *", date(2023, 5, 10, 8, 10), date(2023, 5, 10, 7, 50)},
+		{"last day of previous month", "0 22 31 * *", date(2023, 5, 10, 0, 0), date(2023, 3, 31, 22, 0)},
+		{"previous month", "0 0 15 * *", date(2023, 3, 1, 0, 0), date(2023, 2, 15, 0, 0)},
+		{"previous year", "0 0 1 12 *", date(2023, 5, 10, 0, 0), date(2022, 12, 1, 0, 0)},
+		{"weekday across month", "0 6 * * 5", date(2023, 6, 1, 0, 0), date(2023, 5, 26, 6, 0)},
+		{"29th of february", "0 0 29 2 *", date(2023, 5, 10, 0, 0), date(2020, 2, 29, 0, 0)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ParseCron(test.expression)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) returned error %s", test.expression, err)
+			}
+			previous, ok := schedule.Previous(test.time)
+			if !ok {
+				t.Fatalf("Previous(%s) of %q found no start", test.time, test.expression)
+			}
+			if !previous.Equal(test.previous) {
+				t.Errorf("Previous(%s) of %q = %s, want %s", test.time, test.expression, previous, test.previous)
+			}
+		})
+	}
+}
+
+func TestCronPreviousDaylightSaving(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// on 2023-03-26 the clocks in Berlin jump from 02:00 to 03:00, 02:30 does not exist on that day
+	tests := []struct {
+		name     string
+		time     time.Time
+		previous time.Time
+	}{
+		{"before the gap", time.Date(2023, 3, 26, 1, 59, 0, 0, berlin), time.Date(2023, 3, 25, 2, 30, 0, 0, berlin)},
+		{"right after the gap", time.Date(2023, 3, 26, 3, 10, 0, 0, berlin), time.Date(2023, 3, 25, 2, 30, 0, 0, berlin)},
+		// the skipped start is moved forward by the length of the gap
+		{"after the moved start", time.Date(2023, 3, 26, 4, 0, 0, 0, berlin), time.Date(2023, 3, 26, 3, 30, 0, 0, berlin)},
+		{"day after the gap", time.Date(2023, 3, 27, 12, 0, 0, 0, berlin), time.Date(2023, 3, 27, 2, 30, 0, 0, berlin)},
+	}
+
+	schedule, err := ParseCron("30 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			previous, ok := schedule.Previous(test.time)
+			if !ok {
+				t.Fatalf("Previous(%s) found no start", test.time)
+			}
+			if !previous.Equal(test.previous) {
+				t.Errorf("Previous(%s) = %s, want %s", test.time, previous, test.previous)
+			}
+			if previous.After(test.time) {
+				t.Errorf("Previous(%s) = %s is after the given time", test.time, previous)
+			}
+		})
+	}
+}
+
+func date(year int, month time.Month, day int, hour int, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"math"
+
+	"flygon/config"
+	"golang.org/x/exp/slices"
+)
+
+// modeAccountGroups returns the account groups configured for the work of a worker in the area
+func modeAccountGroups(area *WorkerArea, mode Mode) []string {
+	groups := config.Config.Worker.AccountGroups
+	if area != nil && area.Id == math.MaxInt32 {
+		return groups.Encounter
+	}
+	switch mode {
+	case FortMode:
+		return groups.Fort
+	case LevelingMode:
+		return groups.Leveling
+	}
+	if area != nil && area.IsQuesting() {
+		return groups.Quest
+	}
+	return groups.Pokemon
+}
+
+// AccountGroups returns the account groups a worker of the area and mode may draw accounts from. If both the
+// mode and the area name groups, only groups named by both are allowed. No groups means accounts without group
+func AccountGroups(areaId int, mode Mode) []string {
+	var area *WorkerArea
+	if areaId != 0 {
+		area = GetWorkerArea(areaId)
+	}
+	modeGroups := modeAccountGroups(area, mode)
+	if area == nil || len(area.AccountGroups) == 0 {
+		return modeGroups
+	}
+	if len(modeGroups) == 0 {
+		return area.AccountGroups
+	}
+
+	groups := []string{}
+	for _, group := range area.AccountGroups {
+		if slices.Contains(modeGroups, group) {
+			groups = append(groups, group)
+		}
+	}
+	if len(groups) == 0 {
+		// no group is allowed by both, the empty group name matches no account
+		return []string{""}
+	}
+	return groups
+}
@@ -0,0 +1,230 @@
+package worker
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+)
+
+// weightedLoad returns the number of workers per weight unit, areas with a higher weight receive proportionally more workers
+func (p *WorkerArea) weightedLoad(workers int) float64 {
+	weight := p.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	return float64(workers) / float64(weight)
+}
+
+// findAreaNeedingWorkers returns the area and mode with the highest priority that needs workers.
+// Within a priority the order of modes decides first, then the lowest weighted load.
+// workerAccessMutex has to be locked
+func findAreaNeedingWorkers(modes []Mode) (*WorkerArea, Mode) {
+	var bestArea *WorkerArea
+	bestMode := PokemonMode
+	bestModeIndex := 0
+	bestLoad := 0.0
+
+	for modeIndex, mode := range modes {
+		for _, a := range workerAreas {
+			totalWorkerInArea := CountWorkersWithAreaAndMode(a.Id, mode)
+			if totalWorkerInArea >= a.targetWorkerCountForMode(mode) {
+				continue
+			}
+
+			load := a.weightedLoad(totalWorkerInArea)
+			if bestArea == nil ||
+				a.Priority > bestArea.Priority ||
+				(a.Priority == bestArea.Priority && modeIndex < bestModeIndex) ||
+				(a.Priority == bestArea.Priority && modeIndex == bestModeIndex && load < bestLoad) {
+				bestArea = a
+				bestMode = mode
+				bestModeIndex = modeIndex
+				bestLoad = load
+			}
+		}
+	}
+
+	return bestArea, bestMode
+}
+
+// findSpilloverArea returns the spillover area with the highest priority and lowest weighted load,
+// workerAccessMutex has to be locked
+func findSpilloverArea() (*WorkerArea, Mode) {
+	var bestArea *WorkerArea
+	bestLoad := 0.0
+
+	for _, a := range workerAreas {
+		if !a.Spillover {
+			continue
+		}
+
+		load := a.weightedLoad(CountWorkersWithArea(a.Id))
+		if bestArea == nil || a.Priority > bestArea.Priority || (a.Priority == bestArea.Priority && load < bestLoad) {
+			bestArea = a
+			bestLoad = load
+		}
+	}
+
+	if bestArea == nil {
+		return nil, PokemonMode
+	}
+	if bestArea.Leveling {
+		return bestArea, LevelingMode
+	}
+	return bestArea, PokemonMode
+}
+
+// PreemptWorkers moves active workers from lower priority areas into higher priority areas which are
+// missing workers. Leveling workers are never moved, as they use accounts under level 30
+func PreemptWorkers() {
+	workerAccessMutex.Lock()
+	defer workerAccessMutex.Unlock()
+
+	preemptModes := []Mode{PokemonMode, FortMode}
+	changedAreas := make(map[int]*WorkerArea)
+
+	// every worker is moved at most once per run, so areas can't keep taking workers from each other
+	maxMoves := len(GetWorkers())
+	for moves := 0; moves < maxMoves; moves++ {
+		area, mode := findAreaNeedingWorkers(preemptModes)
+		if area == nil {
+			break
+		}
+
+		donor := findPreemptionDonor(area, preemptModes)
+		if donor == nil {
+			break
+		}
+
+		oldArea := workerAreas[donor.AreaId]
+		log.Infof("[WORKERAREA] [%s] Preempting worker from area %d:%s (priority %d) to area %d:%s (priority %d)",
+			donor.Uuid, oldArea.Id, oldArea.Name, oldArea.Priority, area.Id, area.Name, area.Priority)
+
+		donor.ResetAreaAndRoutePart()
+		donor.AreaId = area.Id
+		donor.Mode = mode
+		changedAreas[oldArea.Id] = oldArea
+		changedAreas[area.Id] = area
+	}
+
+	for _, a := range changedAreas {
+		a.RecalculateRouteParts()
+	}
+}
+
+// findPreemptionDonor returns an active worker which can be taken away from a spillover area above its
+// target or from an area with lower priority than the receiving area. Spillover workers are taken first, but only
+// from a mode above its target, then workers of the lowest priority area
+func findPreemptionDonor(receiver *WorkerArea, modes []Mode) *State {
+	var candidateAreas []*WorkerArea
+	for _, a := range workerAreas {
+		if a.Id == math.MaxInt32 || a.Id == receiver.Id {
+			continue
+		}
+		if a.Priority < receiver.Priority || (a.Spillover && a.hasSurplusWorkers()) {
+			candidateAreas = append(candidateAreas, a)
+		}
+	}
+	sort.Slice(candidateAreas, func(i, j int) bool {
+		iSurplus := candidateAreas[i].Spillover && candidateAreas[i].hasSurplusWorkers()
+		jSurplus := candidateAreas[j].Spillover && candidateAreas[j].hasSurplusWorkers()
+		if iSurplus != jSurplus {
+			return iSurplus
+		}
+		return candidateAreas[i].Priority < candidateAreas[j].Priority
+	})
+
+	now := time.Now().Unix()
+	for _, a := range candidateAreas {
+		for _, ws := range GetWorkersWithArea(a.Id) {
+			if _, pinned := GetAssignedAreaId(ws.Uuid); pinned {
+				continue
+			}
+			if !slices.Contains(modes, ws.Mode) || now-ws.LastSeen > workerUnseen {
+				continue
+			}
+			if a.Priority >= receiver.Priority && CountWorkersWithAreaAndMode(a.Id, ws.Mode) <= a.targetWorkerCountForMode(ws.Mode) {
+				// areas of the same or higher priority only give workers of a mode above its target
+				continue
+			}
+			return ws
+		}
+	}
+
+	return nil
+}
+
+func (p *WorkerArea) hasSurplusWorkers() bool {
+	for _, mode := range allocationModes {
+		if CountWorkersWithAreaAndMode(p.Id, mode) > p.targetWorkerCountForMode(mode) {
+			return true
+		}
+	}
+	return false
+}
+
+// evictSurplusWorkers removes workers above the target of a mode from the area and moves them to
+// other areas needing workers. Workers which were not seen recently are evicted first, pinned workers
+// and workers of spillover areas are kept
+func (p *WorkerArea) evictSurplusWorkers(mode Mode) {
+	if p.Spillover {
+		return
+	}
+
+	var candidates []*State
+	for _, ws := range GetWorkersWithArea(p.Id) {
+		if ws.Mode != mode {
+			continue
+		}
+		if _, pinned := GetAssignedAreaId(ws.Uuid); pinned {
+			continue
+		}
+		candidates = append(candidates, ws)
+	}
+
+	surplus := CountWorkersWithAreaAndMode(p.Id, mode) - p.targetWorkerCountForMode(mode)
+	if surplus <= 0 || len(candidates) == 0 {
+		return
+	}
+	if surplus > len(candidates) {
+		surplus = len(candidates)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].LastSeen < candidates[j].LastSeen
+	})
+
+	evicted := candidates[:surplus]
+	for _, ws := range evicted {
+		log.Infof("[WORKERAREA] [%s] Evicting %s worker from area %d:%s", ws.Uuid, mode, p.Id, p.Name)
+		ws.ResetAreaAndRoutePart()
+	}
+	p.RecalculateRouteParts()
+
+	reallocateWorkers(evicted)
+}
+
+// reallocateWorkers allocates active workers without area to areas needing workers and recalculates those areas
+func reallocateWorkers(workers []*State) {
+	changedAreas := make(map[int]*WorkerArea)
+	now := time.Now().Unix()
+	for _, ws := range workers {
+		if ws.AreaId != 0 || now-ws.LastSeen > workerUnseen {
+			continue
+		}
+		area, err := ws.AllocateArea()
+		if err != nil {
+			log.Infof("[WORKERAREA] [%s] Worker stays unallocated: %s", ws.Uuid, err)
+			continue
+		}
+		log.Infof("[WORKERAREA] [%s] Worker moved to area %d:%s", ws.Uuid, area.Id, area.Name)
+		changedAreas[area.Id] = area
+	}
+
+	for _, a := range changedAreas {
+		a.RecalculateRouteParts()
+	}
+}
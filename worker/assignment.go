@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"path"
+	"sync"
+
+	"flygon/db"
+	log "github.com/sirupsen/logrus"
+)
+
+// workerAssignments maps a worker uuid or a uuid pattern (e.g. "phone-*") to the area the worker is pinned to
+var workerAssignments map[string]int
+var workerAssignmentsMutex sync.RWMutex
+
+func LoadWorkerAssignments(dbDetails db.DbDetails) {
+	records, err := db.GetWorkerAssignmentRecords(dbDetails)
+	if err != nil {
+		log.Errorf("[WORKER] Unable to load worker assignments: %s", err)
+		return
+	}
+
+	assignments := make(map[string]int)
+	for _, record := range records {
+		if _, err := path.Match(record.Uuid, ""); err != nil {
+			log.Errorf("[WORKER] Worker assignment '%s' is not a valid pattern: %s", record.Uuid, err)
+			continue
+		}
+		assignments[record.Uuid] = record.AreaId
+	}
+
+	workerAssignmentsMutex.Lock()
+	workerAssignments = assignments
+	workerAssignmentsMutex.Unlock()
+}
+
+// GetAssignedAreaId returns the area a worker is pinned to. An exact uuid match is preferred,
+// otherwise the longest matching pattern is used
+func GetAssignedAreaId(uuid string) (int, bool) {
+	workerAssignmentsMutex.RLock()
+	defer workerAssignmentsMutex.RUnlock()
+
+	if areaId, found := workerAssignments[uuid]; found {
+		return areaId, true
+	}
+
+	bestPattern := ""
+	bestAreaId := 0
+	for pattern, areaId := range workerAssignments {
+		if matched, _ := path.Match(pattern, uuid); matched && len(pattern) > len(bestPattern) {
+			bestPattern = pattern
+			bestAreaId = areaId
+		}
+	}
+
+	return bestAreaId, bestPattern != ""
+}
+
+// allocatePinnedArea moves the worker into the area it is pinned to, returns nil if the worker is not pinned.
+// The returned bool reports whether the worker changed its area
+func (ws *State) allocatePinnedArea() (*WorkerArea, bool) {
+	areaId, pinned := GetAssignedAreaId(ws.Uuid)
+	if !pinned {
+		return nil, false
+	}
+
+	workerAccessMutex.Lock()
+	defer workerAccessMutex.Unlock()
+
+	area, found := workerAreas[areaId]
+	if !found {
+		log.Warnf("[WORKER] [%s] Worker is pinned to unknown area %d, using automatic allocation", ws.Uuid, areaId)
+		return nil, false
+	}
+	if ws.AreaId == area.Id {
+		return area, false
+	}
+
+	oldArea := workerAreas[ws.AreaId]
+	ws.ResetAreaAndRoutePart()
+	ws.AreaId = area.Id
+	if area.Leveling {
+		ws.Mode = LevelingMode
+	}
+	if oldArea != nil {
+		oldArea.RecalculateRouteParts()
+	}
+	log.Infof("[WORKER] [%s] Worker pinned to area %d:%s", ws.Uuid, area.Id, area.Name)
+
+	return area, true
+}
+
+// ApplyWorkerAssignments moves all known workers into the areas they are pinned to
+func ApplyWorkerAssignments() {
+	for _, ws := range GetWorkers() {
+		if area, moved := ws.allocatePinnedArea(); moved {
+			area.RecalculateRouteParts()
+		}
+	}
+}
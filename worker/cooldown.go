@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"time"
+
+	"flygon/geo"
+)
+
+type cooldownStep struct {
+	distance float64 // meters
+	cooldown int64   // seconds
+}
+
+// cooldownTable is the commonly used teleport cooldown after interacting with the game (spin, encounter, catch).
+// A jump up to the given distance needs the given cooldown before the next interaction
+var cooldownTable = []cooldownStep{
+	{distance: 1000, cooldown: 1 * 60},
+	{distance: 5000, cooldown: 2 * 60},
+	{distance: 10000, cooldown: 6 * 60},
+	{distance: 25000, cooldown: 11 * 60},
+	{distance: 30000, cooldown: 14 * 60},
+	{distance: 65000, cooldown: 22 * 60},
+	{distance: 81000, cooldown: 25 * 60},
+	{distance: 100000, cooldown: 35 * 60},
+	{distance: 250000, cooldown: 45 * 60},
+	{distance: 500000, cooldown: 60 * 60},
+	{distance: 750000, cooldown: 75 * 60},
+	{distance: 1000000, cooldown: 90 * 60},
+}
+
+// maxCooldown is used for every jump above the cooldown table
+const maxCooldown = int64(120 * 60)
+
+// interactionRadius is the distance which does not need any cooldown
+const interactionRadius = 40.0
+
+// CooldownForDistance returns the cooldown in seconds needed after a jump of the given meters
+func CooldownForDistance(distance float64) int64 {
+	if distance <= interactionRadius {
+		return 0
+	}
+	for _, step := range cooldownTable {
+		if distance <= step.distance {
+			return step.cooldown
+		}
+	}
+	return maxCooldown
+}
+
+// RecordInteraction remembers the last location as location of an interaction, starting a cooldown
+func (ws *State) RecordInteraction() {
+	ws.Lock()
+	defer ws.Unlock()
+	if ws.lastLocation == geo.UseCurrentLocation {
+		return
+	}
+	ws.lastInteractionLocation = ws.lastLocation
+	ws.lastInteractionTime = time.Now().Unix()
+}
+
+// RemainingCooldown returns the seconds the worker has to wait before interacting at the target location
+func (ws *State) RemainingCooldown(target geo.Location) int64 {
+	ws.Lock()
+	defer ws.Unlock()
+	if ws.lastInteractionTime == 0 {
+		return 0
+	}
+	cooldown := CooldownForDistance(ws.lastInteractionLocation.Distance(target))
+	remaining := ws.lastInteractionTime + cooldown - time.Now().Unix()
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+func (ws *State) resetCooldown() {
+	ws.lastInteractionLocation = geo.Location{}
+	ws.lastInteractionTime = 0
+}
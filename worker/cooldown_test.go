@@ -0,0 +1,42 @@
+package worker
+
+import "testing"
+
+func TestCooldownForDistance(t *testing.T) {
+	tests := []struct {
+		distance float64
+		cooldown int64
+	}{
+		{0, 0},
+		{interactionRadius, 0},
+		{interactionRadius + 0.1, 60},
+		{1000, 60},
+		{1000.1, 2 * 60},
+		{5000, 2 * 60},
+		{5000.1, 6 * 60},
+		{65000, 22 * 60},
+		{65000.1, 25 * 60},
+		{100000, 35 * 60},
+		{100000.1, 45 * 60},
+		{1000000, 90 * 60},
+		{1000000.1, maxCooldown},
+		{20000000, maxCooldown},
+	}
+
+	for _, test := range tests {
+		if cooldown := CooldownForDistance(test.distance); cooldown != test.cooldown {
+			t.Errorf("CooldownForDistance(%f) = %d, want %d", test.distance, cooldown, test.cooldown)
+		}
+	}
+}
+
+func TestCooldownTableIsAscending(t *testing.T) {
+	for x := 1; x < len(cooldownTable); x++ {
+		if cooldownTable[x].distance <= cooldownTable[x-1].distance || cooldownTable[x].cooldown < cooldownTable[x-1].cooldown {
+			t.Errorf("cooldown step %d (%v) does not follow step %d (%v)", x, cooldownTable[x], x-1, cooldownTable[x-1])
+		}
+	}
+	if last := cooldownTable[len(cooldownTable)-1]; last.cooldown > maxCooldown {
+		t.Errorf("last cooldown step %v is above the maximum cooldown %d", last, maxCooldown)
+	}
+}
@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"flygon/geo"
+)
+
+// coverageScanDistance is the maximum distance between a received GMO and the dispatched step to count the step as scanned
+const coverageScanDistance = 100.0
+
+// routeCoverage tracks when every step of the pokemon route was handed out and scanned
+type routeCoverage struct {
+	mu             sync.Mutex
+	lastDispatched []int64
+	lastScanned    []int64
+	passStart      map[string]int64
+	passDuration   map[string]int64
+}
+
+type StepCoverage struct {
+	Step           int
+	Location       geo.Location
+	LastDispatched int64
+	LastScanned    int64
+}
+
+type AreaCoverage struct {
+	Steps          []StepCoverage
+	CycleTime      int64 // seconds of the slowest pass of the current pokemon workers
+	OldestScanAge  int64 // seconds since the least recently scanned step was scanned
+	StaleSteps     int   // steps not scanned within the stale time, including steps never scanned
+	UnscannedSteps int   // steps never scanned
+}
+
+// resize resets the coverage if the length of the route changed, has to be called with locked mutex
+func (c *routeCoverage) resize(steps int) {
+	if len(c.lastScanned) == steps {
+		return
+	}
+	c.lastDispatched = make([]int64, steps)
+	c.lastScanned = make([]int64, steps)
+}
+
+// markDispatched stores the dispatch time of a step, has to be called with locked mutex
+func (c *routeCoverage) markDispatched(step int, routeLength int, now int64) bool {
+	c.resize(routeLength)
+	if step < 0 || step >= len(c.lastDispatched) {
+		return false
+	}
+	c.lastDispatched[step] = now
+	return true
+}
+
+// RecordStepDispatched stores that a step of the pokemon route was handed out to the worker. Starting
+// at the first step of its route part finishes the previous pass of the worker
+func (p *WorkerArea) RecordStepDispatched(ws *State, step int) {
+	now := time.Now().Unix()
+	c := &p.coverage
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.markDispatched(p.RouteIndexOfStep(PokemonMode, step), len(p.pokemonRoute), now) {
+		return
+	}
+
+	if step != ws.StartStep {
+		return
+	}
+	if c.passStart == nil {
+		c.passStart = make(map[string]int64)
+		c.passDuration = make(map[string]int64)
+	}
+	if start, found := c.passStart[ws.Uuid]; found {
+		c.passDuration[ws.Uuid] = now - start
+	}
+	c.passStart[ws.Uuid] = now
+}
+
+// RecordStepScanned marks the route index as scanned if the scanned location is near the step location
+func (p *WorkerArea) RecordStepScanned(step int, location geo.Location) {
+	route := p.pokemonRoute
+	if step < 0 || step >= len(route) || route[step].Distance(location) > coverageScanDistance {
+		return
+	}
+
+	c := &p.coverage
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resize(len(p.pokemonRoute))
+	if step < len(c.lastScanned) {
+		c.lastScanned[step] = time.Now().Unix()
+	}
+}
+
+// Coverage returns the scan state of every pokemon route step, steps not scanned within staleAfter seconds count as stale
+func (p *WorkerArea) Coverage(staleAfter int64) AreaCoverage {
+	now := time.Now().Unix()
+	route := p.pokemonRoute
+
+	workers := make(map[string]bool)
+	for _, ws := range GetWorkersWithArea(p.Id) {
+		if ws.Mode == PokemonMode {
+			workers[ws.Uuid] = true
+		}
+	}
+
+	c := &p.coverage
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resize(len(route))
+
+	coverage := AreaCoverage{Steps: make([]StepCoverage, 0, len(route))}
+	oldestScan := int64(0)
+	for step, location := range route {
+		lastScanned := c.lastScanned[step]
+		coverage.Steps = append(coverage.Steps, StepCoverage{
+			Step:           step,
+			Location:       location,
+			LastDispatched: c.lastDispatched[step],
+			LastScanned:    lastScanned,
+		})
+
+		if lastScanned == 0 {
+			coverage.UnscannedSteps++
+		} else if oldestScan == 0 || lastScanned < oldestScan {
+			oldestScan = lastScanned
+		}
+		if now-lastScanned > staleAfter {
+			coverage.StaleSteps++
+		}
+	}
+	if oldestScan > 0 {
+		coverage.OldestScanAge = now - oldestScan
+	}
+
+	for uuid, duration := range c.passDuration {
+		if !workers[uuid] {
+			// worker left the area, its passes do not describe the current route parts
+			delete(c.passStart, uuid)
+			delete(c.passDuration, uuid)
+			continue
+		}
+		if duration > coverage.CycleTime {
+			coverage.CycleTime = duration
+		}
+	}
+
+	return coverage
+}
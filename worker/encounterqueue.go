@@ -0,0 +1,144 @@
+package worker
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+	"time"
+
+	"flygon/geo"
+	"github.com/jellydator/ttlcache/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+type EncounterTarget struct {
+	EncounterId   uint64
+	PokemonId     int32
+	WeatherBoost  int32
+	Location      geo.Location
+	IsSpawnpoint  bool
+	Priority      int
+	Expiry        time.Time
+	insertedOrder uint64
+}
+
+const defaultEncounterTargetTtl = 10 * time.Minute
+const maxEncounterQueueSize = 10000
+
+// encounterQueue implements heap.Interface, highest priority first and oldest first within a priority
+type encounterQueue []*EncounterTarget
+
+func (q encounterQueue) Len() int { return len(q) }
+
+func (q encounterQueue) Less(i, j int) bool {
+	if q[i].Priority != q[j].Priority {
+		return q[i].Priority > q[j].Priority
+	}
+	return q[i].insertedOrder < q[j].insertedOrder
+}
+
+func (q encounterQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *encounterQueue) Push(x any) {
+	*q = append(*q, x.(*EncounterTarget))
+}
+
+func (q *encounterQueue) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return item
+}
+
+var encounterTargets encounterQueue
+var encounterInsertCounter uint64
+var encounterQueueMutex sync.Mutex
+
+func (t *EncounterTarget) cacheKey() encounterCacheKey {
+	return encounterCacheKey{
+		encounterId:  t.EncounterId,
+		pokemonId:    t.PokemonId,
+		weatherBoost: t.WeatherBoost,
+	}
+}
+
+// AddEncounterTarget queues a pokemon for the encounter workers, returns false when
+// the target is expired, full or was already queued or encountered
+func AddEncounterTarget(target EncounterTarget) bool {
+	now := time.Now()
+	if target.Expiry.IsZero() {
+		target.Expiry = now.Add(defaultEncounterTargetTtl)
+	}
+	if target.Expiry.Before(now) {
+		return false
+	}
+
+	area := GetWorkerArea(math.MaxInt32)
+	if area == nil {
+		return false
+	}
+	key := target.cacheKey()
+	if area.pokemonEncounterCache.Has(key) {
+		return false
+	}
+
+	encounterQueueMutex.Lock()
+	defer encounterQueueMutex.Unlock()
+
+	if len(encounterTargets) >= maxEncounterQueueSize {
+		purgeExpiredEncounterTargets(now)
+		if len(encounterTargets) >= maxEncounterQueueSize {
+			log.Warnf("[ENCOUNTER] Encounter queue is full, dropping target %d", target.EncounterId)
+			return false
+		}
+	}
+
+	encounterInsertCounter++
+	target.insertedOrder = encounterInsertCounter
+	heap.Push(&encounterTargets, &target)
+	area.pokemonEncounterCache.Set(key, false, ttlcache.DefaultTTL)
+
+	return true
+}
+
+// GetNextEncounterTarget returns the highest priority target which did not expire yet
+func GetNextEncounterTarget() (*EncounterTarget, bool) {
+	encounterQueueMutex.Lock()
+	defer encounterQueueMutex.Unlock()
+
+	now := time.Now()
+	for len(encounterTargets) > 0 {
+		target := heap.Pop(&encounterTargets).(*EncounterTarget)
+		if target.Expiry.After(now) {
+			if area := GetWorkerArea(math.MaxInt32); area != nil {
+				area.pokemonEncounterCache.Set(target.cacheKey(), true, ttlcache.DefaultTTL)
+			}
+			return target, true
+		}
+	}
+
+	return nil, false
+}
+
+func EncounterQueueLength() int {
+	encounterQueueMutex.Lock()
+	defer encounterQueueMutex.Unlock()
+	return len(encounterTargets)
+}
+
+// purgeExpiredEncounterTargets removes expired targets, encounterQueueMutex has to be locked
+func purgeExpiredEncounterTargets(now time.Time) {
+	validTargets := encounterTargets[:0]
+	for _, target := range encounterTargets {
+		if target.Expiry.After(now) {
+			validTargets = append(validTargets, target)
+		}
+	}
+	for x := len(validTargets); x < len(encounterTargets); x++ {
+		encounterTargets[x] = nil
+	}
+	encounterTargets = validTargets
+	heap.Init(&encounterTargets)
+}
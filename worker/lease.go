@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flygon/config"
+	"flygon/geo"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+)
+
+// maxLeaseAttempts is the number of times a pokemon step is dispatched before it is given up
+const maxLeaseAttempts = 3
+
+// retryExpiryTimeouts is the number of lease timeouts a step waits for a retry before it is dropped
+const retryExpiryTimeouts = 5
+
+var lastLeaseId atomic.Uint64
+
+// jobLease is a dispatched pokemon route step waiting for its GMO
+type jobLease struct {
+	Id         uint64
+	Step       int // index in the pokemon route
+	Location   geo.Location
+	Worker     string
+	Dispatched int64
+	Queued     int64 // time the lease was queued for retry
+	Attempts   int
+}
+
+type jobLeases struct {
+	mu      sync.Mutex
+	pending map[uint64]*jobLease
+	retry   []*jobLease
+}
+
+func jobLeaseTimeout() int64 {
+	return int64(config.Config.Worker.JobLeaseTimeout)
+}
+
+// LeaseStep registers a dispatched pokemon step, it has to be acknowledged by a GMO of the worker within the lease timeout
+func (p *WorkerArea) LeaseStep(ws *State, step int, location geo.Location) {
+	if jobLeaseTimeout() <= 0 {
+		return
+	}
+	p.leases.add(&jobLease{
+		Step:     p.RouteIndexOfStep(PokemonMode, step),
+		Location: location,
+		Worker:   ws.Uuid,
+		Attempts: 1,
+	})
+}
+
+func (l *jobLeases) add(lease *jobLease) {
+	lease.Id = lastLeaseId.Add(1)
+	lease.Dispatched = time.Now().Unix()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.pending == nil {
+		l.pending = make(map[uint64]*jobLease)
+	}
+	l.pending[lease.Id] = lease
+}
+
+// AcknowledgeScan completes the pending lease of the worker near the scanned location and marks its step as scanned
+func (p *WorkerArea) AcknowledgeScan(ws *State, location geo.Location) {
+	if jobLeaseTimeout() <= 0 {
+		p.RecordStepScanned(p.RouteIndexOfStep(PokemonMode, ws.Step), location)
+		return
+	}
+
+	p.leases.mu.Lock()
+	var acknowledged *jobLease
+	for id, lease := range p.leases.pending {
+		if lease.Worker == ws.Uuid && lease.Location.Distance(location) <= coverageScanDistance {
+			acknowledged = lease
+			delete(p.leases.pending, id)
+			break
+		}
+	}
+	p.leases.mu.Unlock()
+
+	if acknowledged == nil {
+		return
+	}
+	log.Debugf("[WORKERAREA] [%s] Lease %d of step %d acknowledged", ws.Uuid, acknowledged.Id, acknowledged.Step)
+	p.RecordStepScanned(acknowledged.Step, location)
+}
+
+// expire moves leases past the timeout into the retry queue, once per step, and drops retries
+// which were not picked up within retryExpiryTimeouts lease timeouts. l.mu has to be locked
+func (l *jobLeases) expire(areaName string, now int64, timeout int64) {
+	for id, lease := range l.pending {
+		if now-lease.Dispatched <= timeout {
+			continue
+		}
+		delete(l.pending, id)
+		if lease.Attempts >= maxLeaseAttempts {
+			log.Warnf("[WORKERAREA] %s: Step %d was not scanned after %d attempts, giving up", areaName, lease.Step, lease.Attempts)
+			continue
+		}
+		if slices.ContainsFunc(l.retry, func(r *jobLease) bool { return r.Step == lease.Step }) {
+			continue
+		}
+		log.Infof("[WORKERAREA] [%s] Lease %d of step %d in %s expired, queued for retry", lease.Worker, lease.Id, lease.Step, areaName)
+		lease.Queued = now
+		l.retry = append(l.retry, lease)
+	}
+
+	retry := l.retry[:0]
+	for _, lease := range l.retry {
+		if now-lease.Queued > retryExpiryTimeouts*timeout {
+			log.Debugf("[WORKERAREA] %s: Retry of step %d was not picked up, dropped", areaName, lease.Step)
+			continue
+		}
+		retry = append(retry, lease)
+	}
+	l.retry = retry
+}
+
+// ExpireLeases queues expired leases for retry, so leases of areas without active workers don't pile up
+func (p *WorkerArea) ExpireLeases() {
+	timeout := jobLeaseTimeout()
+	if timeout <= 0 {
+		return
+	}
+	p.leases.mu.Lock()
+	defer p.leases.mu.Unlock()
+	p.leases.expire(p.Name, time.Now().Unix(), timeout)
+}
+
+// GetRetryStep hands out a step whose lease expired, preferably to a worker other than the one which failed it.
+// The failing worker gets the step after it waited one lease timeout for another worker. The step is leased again
+// to the worker
+func (p *WorkerArea) GetRetryStep(ws *State) (int, geo.Location, bool) {
+	timeout := jobLeaseTimeout()
+	if timeout <= 0 {
+		return 0, geo.Location{}, false
+	}
+	now := time.Now().Unix()
+
+	l := &p.leases
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.expire(p.Name, now, timeout)
+
+	for x, lease := range l.retry {
+		if lease.Worker == ws.Uuid && now-lease.Queued <= timeout {
+			continue
+		}
+		l.retry = append(l.retry[:x], l.retry[x+1:]...)
+
+		retry := &jobLease{
+			Id:         lastLeaseId.Add(1),
+			Step:       lease.Step,
+			Location:   lease.Location,
+			Worker:     ws.Uuid,
+			Dispatched: now,
+			Attempts:   lease.Attempts + 1,
+		}
+		if l.pending == nil {
+			l.pending = make(map[uint64]*jobLease)
+		}
+		l.pending[retry.Id] = retry
+
+		p.coverage.mu.Lock()
+		p.coverage.markDispatched(retry.Step, len(p.pokemonRoute), now)
+		p.coverage.mu.Unlock()
+
+		return retry.Step, retry.Location, true
+	}
+
+	return 0, geo.Location{}, false
+}
@@ -3,12 +3,9 @@ package worker
 import (
 	"math"
 	"os"
-	// "time"
 
-	// "flygon/config"
 	"flygon/db"
 	"flygon/geo"
-	// "github.com/go-co-op/gocron"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/exp/slices"
 )
@@ -19,6 +16,8 @@ func StartAreas(dbDetails db.DbDetails) {
 	naughtyDetails = dbDetails // temp steal these
 
 	areas, _ := db.GetAreaRecords(dbDetails)
+	LoadAreaSchedules(dbDetails)
+	LoadWorkerAssignments(dbDetails)
 
 	for _, area := range areas {
 		areaRoute, err := db.ParseRouteFromString(area.PokemonModeRoute.ValueOrZero())
@@ -28,6 +27,13 @@ func StartAreas(dbDetails db.DbDetails) {
 			os.Exit(1)
 		}
 
+		fortRoute, err := db.ParseRouteFromString(area.FortModeRoute.ValueOrZero())
+
+		if err != nil {
+			log.Errorf("Fort route in area %d:%s is malformatted", area.Id, area.Name)
+			os.Exit(1)
+		}
+
 		geofenceLocations, err := db.ParseRouteFromString(area.Geofence.ValueOrZero())
 
 		if err != nil {
@@ -50,13 +56,22 @@ func StartAreas(dbDetails db.DbDetails) {
 		noWorkers := area.PokemonModeWorkers
 		areaName := area.Name
 
-		workerArea := NewWorkerArea(area.Id, areaName, noWorkers, areaRoute, geo.Geofence{Fence: geofenceLocations}, questRoute, questCheckHours)
+		workerArea := NewWorkerArea(area.Id, areaName, noWorkers, areaRoute, area.FortModeWorkers, fortRoute, geo.Geofence{Fence: geofenceLocations}, questRoute, questCheckHours)
+		workerArea.Leveling = area.EnableLeveling
+		workerArea.Priority = area.Priority
+		workerArea.Weight = area.Weight
+		workerArea.Spillover = area.Spillover
+		workerArea.RoutePartition = area.RoutePartition
+		workerArea.AccountGroups = db.ParseAccountGroupsFromString(area.AccountGroups.ValueOrZero())
+		if len(questRoute) > 0 {
+			workerArea.restoreQuestChecks(dbDetails)
+		}
 		RegisterArea(workerArea)
 
 		//go workerArea.Start()
 	}
 	// register unbound area
-	RegisterArea(&WorkerArea{
+	unboundArea := &WorkerArea{
 		Id:                 math.MaxInt32,
 		Name:               "unbound",
 		TargetWorkerCount:  0,
@@ -66,15 +81,13 @@ func StartAreas(dbDetails db.DbDetails) {
 		questRoute:         nil,
 		questCheckHours:    nil,
 		questCheckLastHour: -1,
-	})
-
-	// come back when quests are ready
-	// if config.Config.Koji.Url != "" {
-	// 	s := gocron.NewScheduler(time.UTC)
-	// 	s.Every(1).Hour().Do(QuestRouteBuilder)
-	// 	s.StartAsync()
-	// }
+	}
+	unboundArea.startCache() // encounter targets are deduplicated by the unbound area
+	RegisterArea(unboundArea)
+
 	StartWorkerRoutePartRecalculationScheduler()
+	StartQuestCheckScheduler()
+	StartAreaScheduler()
 }
 
 func StartQuest(areaId int) bool {
@@ -91,6 +104,8 @@ func StartQuest(areaId int) bool {
 
 func ReloadAreas(dbDetails db.DbDetails) {
 	areas, _ := db.GetAreaRecords(dbDetails)
+	LoadAreaSchedules(dbDetails)
+	LoadWorkerAssignments(dbDetails)
 	currentAreas := GetWorkerAreas()
 	var checked []int
 
@@ -102,6 +117,13 @@ func ReloadAreas(dbDetails db.DbDetails) {
 			areaRoute = []geo.Location{}
 		}
 
+		fortRoute, err := db.ParseRouteFromString(area.FortModeRoute.ValueOrZero())
+
+		if err != nil {
+			log.Errorf("Fort route in area %d:%s is malformatted - will continue as this is hot reload", area.Id, area.Name)
+			fortRoute = []geo.Location{}
+		}
+
 		geofenceLocations, err := db.ParseRouteFromString(area.Geofence.ValueOrZero())
 
 		if err != nil {
@@ -141,16 +163,52 @@ func ReloadAreas(dbDetails db.DbDetails) {
 					current.AdjustRoute(areaRoute)
 				}
 
+				if !slices.Equal(fortRoute, current.fortRoute) {
+					log.Infof("RELOAD: Area %d / %s fort route change", current.Id, current.Name)
+					current.AdjustFortRoute(fortRoute)
+				}
+
 				if !slices.Equal(questRoute, current.questRoute) {
 					log.Infof("RELOAD: Area %d / %s quest route change", current.Id, current.Name)
 					current.AdjustQuestRoute(questRoute)
 				}
 
-				if current.TargetWorkerCount != area.PokemonModeWorkers {
+				// worker counts of scheduled areas are set by their active schedule
+				scheduled := current.hasSchedules()
+
+				if !scheduled && current.TargetWorkerCount != area.PokemonModeWorkers {
 					log.Infof("RELOAD: Area %d / %s worker change %d->%d", current.Id, current.Name, current.TargetWorkerCount, area.PokemonModeWorkers)
 					current.AdjustWorkers(area.PokemonModeWorkers)
 				}
 
+				if !scheduled && current.FortTargetWorkerCount != area.FortModeWorkers {
+					log.Infof("RELOAD: Area %d / %s fort worker change %d->%d", current.Id, current.Name, current.FortTargetWorkerCount, area.FortModeWorkers)
+					current.AdjustFortWorkers(area.FortModeWorkers)
+				}
+
+				if current.Leveling != area.EnableLeveling {
+					log.Infof("RELOAD: Area %d / %s leveling change %t->%t", current.Id, current.Name, current.Leveling, area.EnableLeveling)
+					current.AdjustLeveling(area.EnableLeveling)
+				}
+
+				if current.Priority != area.Priority || current.Weight != area.Weight || current.Spillover != area.Spillover {
+					log.Infof("RELOAD: Area %d / %s allocation change priority %d->%d weight %d->%d spillover %t->%t", current.Id, current.Name,
+						current.Priority, area.Priority, current.Weight, area.Weight, current.Spillover, area.Spillover)
+					current.Priority = area.Priority
+					current.Weight = area.Weight
+					current.Spillover = area.Spillover
+				}
+
+				if current.RoutePartition != area.RoutePartition {
+					log.Infof("RELOAD: Area %d / %s route partition change %s->%s", current.Id, current.Name, current.RoutePartition, area.RoutePartition)
+					current.AdjustRoutePartition(area.RoutePartition)
+				}
+
+				if accountGroups := db.ParseAccountGroupsFromString(area.AccountGroups.ValueOrZero()); !slices.Equal(accountGroups, current.AccountGroups) {
+					log.Infof("RELOAD: Area %d / %s account groups change %v->%v", current.Id, current.Name, current.AccountGroups, accountGroups)
+					current.AccountGroups = accountGroups
+				}
+
 				if !slices.Equal(questCheckHours, current.questCheckHours) {
 					log.Infof("RELOAD: Area #%d / %s quest check hours change", current.Id, current.Name)
 					current.AdjustQuestCheckHours(questCheckHours)
@@ -165,7 +223,16 @@ func ReloadAreas(dbDetails db.DbDetails) {
 			noWorkers := area.PokemonModeWorkers
 			areaName := area.Name
 
-			workerArea := NewWorkerArea(area.Id, areaName, noWorkers, areaRoute, geo.Geofence{Fence: geofenceLocations}, questRoute, questCheckHours)
+			workerArea := NewWorkerArea(area.Id, areaName, noWorkers, areaRoute, area.FortModeWorkers, fortRoute, geo.Geofence{Fence: geofenceLocations}, questRoute, questCheckHours)
+			workerArea.Leveling = area.EnableLeveling
+			workerArea.Priority = area.Priority
+			workerArea.Weight = area.Weight
+			workerArea.Spillover = area.Spillover
+			workerArea.RoutePartition = area.RoutePartition
+			workerArea.AccountGroups = db.ParseAccountGroupsFromString(area.AccountGroups.ValueOrZero())
+			if len(questRoute) > 0 {
+				workerArea.restoreQuestChecks(dbDetails)
+			}
 			RegisterArea(workerArea)
 
 			//go workerArea.Start()
@@ -175,12 +242,15 @@ func ReloadAreas(dbDetails db.DbDetails) {
 	// Remove any areas no longer in DB
 
 	for _, current := range currentAreas {
-		if !slices.Contains(checked, current.Id) {
+		if !slices.Contains(checked, current.Id) && current.Id != math.MaxInt32 {
 			// Close workersAssigned
 			log.Infof("RELOAD: Shutting down area %d / %s", current.Id, current.Name)
 
 			current.AdjustWorkers(0)
+			current.AdjustFortWorkers(0)
 			RemoveArea(current)
 		}
 	}
+
+	checkAreaSchedules()
 }
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"sort"
+
+	"flygon/geo"
+)
+
+// routePartition caches the clusters of a route for a number of workers, k-means is too expensive to run on every recalculation
+type routePartition struct {
+	routeLength int
+	parts       int
+	clusters    [][]int
+}
+
+// RouteIndexOfStep returns the index in the mode's route of the given step. Steps are positions in the
+// partition order of the area, which is the route itself unless the area uses cluster partitioning
+func (p *WorkerArea) RouteIndexOfStep(mode Mode, step int) int {
+	p.partitionMutex.RLock()
+	defer p.partitionMutex.RUnlock()
+
+	order := p.routeOrder[mode]
+	if step < 0 || step >= len(order) {
+		return step
+	}
+	return order[step]
+}
+
+func (p *WorkerArea) setRouteOrder(mode Mode, order []int) {
+	p.partitionMutex.Lock()
+	defer p.partitionMutex.Unlock()
+
+	if p.routeOrder == nil {
+		p.routeOrder = make(map[Mode][]int)
+	}
+	p.routeOrder[mode] = order
+}
+
+func (p *WorkerArea) resetPartitionCache() {
+	p.partitionMutex.Lock()
+	defer p.partitionMutex.Unlock()
+	p.partitionCache = nil
+}
+
+func (p *WorkerArea) clustersForMode(mode Mode, route []geo.Location, parts int) [][]int {
+	p.partitionMutex.Lock()
+	defer p.partitionMutex.Unlock()
+
+	if cached, found := p.partitionCache[mode]; found && cached.routeLength == len(route) && cached.parts == parts {
+		return cached.clusters
+	}
+	clusters := geo.PartitionRoute(route, parts)
+	if p.partitionCache == nil {
+		p.partitionCache = make(map[Mode]routePartition)
+	}
+	p.partitionCache[mode] = routePartition{routeLength: len(route), parts: parts, clusters: clusters}
+	return clusters
+}
+
+// recalculateClusterRouteParts gives every worker a compact geographic cluster of the route. The start positions
+// of the workers are staggered within their clusters, so the passes of neighbouring clusters are spread over time
+func (p *WorkerArea) recalculateClusterRouteParts(mode Mode, activeWorkers []*State) {
+	route := p.routeForMode(mode)
+	if len(activeWorkers) == 0 || len(route) == 0 {
+		return
+	}
+
+	// keep the cluster of a worker stable between recalculations
+	sort.Slice(activeWorkers, func(i, j int) bool {
+		return activeWorkers[i].Uuid < activeWorkers[j].Uuid
+	})
+
+	clusters := p.clustersForMode(mode, route, len(activeWorkers))
+	order := make([]int, 0, len(route))
+	for _, cluster := range clusters {
+		order = append(order, cluster...)
+	}
+	p.setRouteOrder(mode, order)
+
+	startStep := 0
+	for i, ws := range activeWorkers {
+		if i >= len(clusters) {
+			// more workers than route steps, share the last cluster
+			ws.StartStep = len(order) - 1
+			ws.EndStep = ws.StartStep
+			ws.Step = ws.StartStep
+			continue
+		}
+		clusterLength := len(clusters[i])
+		ws.StartStep = startStep
+		ws.EndStep = startStep + clusterLength - 1
+		if ws.Step < ws.StartStep || ws.Step > ws.EndStep {
+			ws.Step = ws.StartStep + i*clusterLength/len(activeWorkers)
+		}
+		startStep += clusterLength
+	}
+}
@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"time"
+
+	"flygon/db"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v4"
+)
+
+// workerStateSaveInterval is the interval in which worker states are written to the database
+const workerStateSaveInterval = 30 * time.Second
+
+// RestoreWorkerState loads the persisted worker states, so devices resume their area, route position and account
+// after a restart. reserveAccount is called for every restored username and has to return false if the account
+// can not be used anymore. Needs to be called after the areas were started
+func RestoreWorkerState(dbDetails db.DbDetails, reserveAccount func(username string) bool) {
+	records, err := db.GetWorkerRecords(dbDetails)
+	if err != nil {
+		log.Errorf("[WORKER] Unable to restore worker states: %s", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	restored := 0
+	restoredAreas := make(map[int]*WorkerArea)
+
+	workerAccessMutex.RLock()
+	statesMutex.Lock()
+	for _, record := range records {
+		ws := &State{
+			Uuid:           record.Uuid,
+			Host:           record.Host,
+			LastSeen:       record.LastSeen,
+			requestCounter: NewRequestCounter(),
+			stats:          newWorkerStats(),
+		}
+		ws.SetRequestLimits(requestLimits)
+
+		// the assignment is kept as long as the area exists, however long the restart took. Restored workers
+		// count as seen now, workers which don't come back are dropped by the next recalculation after workerUnseen
+		if area, found := workerAreas[record.AreaId]; found {
+			restoredAreas[area.Id] = area
+			ws.AreaId = record.AreaId
+			ws.Mode = Mode(record.Mode)
+			ws.StartStep = record.StartStep
+			ws.EndStep = record.EndStep
+			ws.Step = record.Step
+			ws.LastSeen = now
+			restored++
+		}
+		if username := record.Username.ValueOrZero(); username != "" && reserveAccount(username) {
+			ws.Username = username
+		}
+
+		states[record.Uuid] = ws
+	}
+	statesMutex.Unlock()
+	workerAccessMutex.RUnlock()
+
+	// route parts keep the restored steps, but the partition order of the areas has to be built again
+	for _, area := range restoredAreas {
+		area.RecalculateRouteParts()
+	}
+
+	log.Infof("[WORKER] Restored %d worker states, %d of them with area", len(records), restored)
+}
+
+// SaveWorkerState writes all worker states to the database
+func SaveWorkerState(dbDetails db.DbDetails) {
+	workers := GetWorkers()
+	records := make([]db.Worker, 0, len(workers))
+	for _, ws := range workers {
+		ws.Lock()
+		records = append(records, db.Worker{
+			Uuid:      ws.Uuid,
+			AreaId:    ws.AreaId,
+			Mode:      int(ws.Mode),
+			Username:  null.NewString(ws.Username, ws.Username != ""),
+			StartStep: ws.StartStep,
+			EndStep:   ws.EndStep,
+			Step:      ws.Step,
+			Host:      ws.Host,
+			LastSeen:  ws.LastSeen,
+		})
+		ws.Unlock()
+	}
+
+	if err := db.SaveWorkers(dbDetails, records); err != nil {
+		log.Errorf("[WORKER] Unable to save worker states: %s", err)
+	}
+}
+
+func StartWorkerStateSaver(dbDetails db.DbDetails) {
+	ticker := time.NewTicker(workerStateSaveInterval)
+	go func() {
+		for {
+			<-ticker.C
+			SaveWorkerState(dbDetails)
+		}
+	}()
+}
@@ -0,0 +1,168 @@
+package worker
+
+import (
+	"encoding/json"
+	"time"
+
+	"flygon/db"
+	"flygon/geo"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+)
+
+// questCheckStepDistance is the distance in meters a stored quest check location may differ from its
+// quest step, quest_check stores coordinates as single precision floats
+const questCheckStepDistance = 10.0
+
+// questCheckOfStep returns the quest check of a quest step with all pokestops scanned from it,
+// questMutex has to be locked
+func (p *WorkerArea) questCheckOfStep(stepNo int) (db.QuestCheck, bool) {
+	if stepNo < 0 || stepNo >= len(p.questRoute) {
+		return db.QuestCheck{}, false
+	}
+
+	pokestops := make(map[string]*PokestopQuestInfo)
+	for _, fortId := range p.questStepStops[stepNo] {
+		item := p.pokestopCache.Get(fortId)
+		if item == nil {
+			continue
+		}
+		pokestop := item.Value()
+		for _, scan := range pokestop.ScanData {
+			if !scan.ScannedTime.IsZero() && scan.StepNo == stepNo {
+				pokestops[fortId] = pokestop
+			}
+		}
+	}
+
+	pokestopsJson, err := json.Marshal(pokestops)
+	if err != nil {
+		log.Errorf("[QUEST] %s: Failed to marshal quest check of step %d: %s", p.Name, stepNo, err)
+		return db.QuestCheck{}, false
+	}
+
+	location := p.questRoute[stepNo]
+	return db.QuestCheck{
+		AreaId:    p.Id,
+		Lat:       location.Latitude,
+		Lon:       location.Longitude,
+		Pokestops: string(pokestopsJson),
+	}, true
+}
+
+// indexQuestStepStop remembers that the pokestop was scanned from the quest step, questMutex has to be locked
+func (p *WorkerArea) indexQuestStepStop(stepNo int, fortId string) {
+	if p.questStepStops == nil {
+		p.questStepStops = make(map[int][]string)
+	}
+	if !slices.Contains(p.questStepStops[stepNo], fortId) {
+		p.questStepStops[stepNo] = append(p.questStepStops[stepNo], fortId)
+	}
+}
+
+func (p *WorkerArea) saveQuestCheck(questCheck db.QuestCheck) {
+	if err := db.SaveQuestCheck(naughtyDetails, questCheck); err != nil {
+		log.Errorf("[QUEST] %s: Failed to save quest check: %s", p.Name, err)
+	}
+}
+
+func (p *WorkerArea) deleteQuestChecks() {
+	if err := db.DeleteQuestChecks(naughtyDetails, p.Id); err != nil {
+		log.Errorf("[QUEST] %s: Failed to delete quest checks: %s", p.Name, err)
+	}
+}
+
+// findQuestStep returns the quest step at the location
+func (p *WorkerArea) findQuestStep(location geo.Location) (int, bool) {
+	best, bestDistance := -1, questCheckStepDistance
+	for step, stepLocation := range p.questRoute {
+		if distance := stepLocation.Distance(location); distance <= bestDistance {
+			best, bestDistance = step, distance
+		}
+	}
+	return best, best >= 0
+}
+
+// restoreQuestChecks reloads the quest checks of an interrupted quest run and resumes questing with the steps
+// not scanned yet. Quest checks from before the last local midnight are outdated and removed
+func (p *WorkerArea) restoreQuestChecks(dbDetails db.DbDetails) {
+	questChecks, err := db.GetQuestCheckRecords(dbDetails, p.Id)
+	if err != nil {
+		log.Errorf("[QUEST] %s: Failed to load quest checks: %s", p.Name, err)
+		return
+	}
+	if len(questChecks) == 0 {
+		return
+	}
+
+	localNow := time.Now().In(p.Timezone())
+	midnight := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, localNow.Location())
+
+	p.questMutex.Lock()
+	defer p.questMutex.Unlock()
+
+	var scanned [2]map[int]bool
+	for layer := range scanned {
+		scanned[layer] = make(map[int]bool)
+	}
+	questStartTime := localNow
+	restoredStops := 0
+	for _, questCheck := range questChecks {
+		step, ok := p.findQuestStep(geo.Location{Latitude: questCheck.Lat, Longitude: questCheck.Lon})
+		if !ok {
+			continue
+		}
+
+		pokestops := make(map[string]*PokestopQuestInfo)
+		if err := json.Unmarshal([]byte(questCheck.Pokestops), &pokestops); err != nil {
+			log.Warnf("[QUEST] %s: Ignoring malformed quest check at %f,%f: %s", p.Name, questCheck.Lat, questCheck.Lon, err)
+			continue
+		}
+
+		for fortId, restored := range pokestops {
+			pokestop := p.GetPokestopStatus(fortId)
+			for layer, scan := range restored.ScanData {
+				if scan.ScannedTime.Before(midnight) {
+					continue
+				}
+				scan.StepNo = step
+				pokestop.ScanData[layer] = scan
+				p.indexQuestStepStop(step, fortId)
+				scanned[layer][step] = true
+				if scan.ScannedTime.Before(questStartTime) {
+					questStartTime = scan.ScannedTime
+				}
+			}
+			if restored.HasArQuestReward {
+				pokestop.HasArQuestReward = true
+			}
+			restoredStops++
+		}
+	}
+
+	if len(scanned[Quest_Layer_AR]) == 0 && len(scanned[Quest_Layer_NoAr]) == 0 {
+		log.Infof("[QUEST] %s: Stored quest checks are outdated", p.Name)
+		p.pokestopCache.DeleteAll()
+		p.questStepStops = nil
+		go p.deleteQuestChecks()
+		return
+	}
+
+	for layer := range p.questLayers {
+		order := []int{}
+		for step := range p.questRoute {
+			if !scanned[layer][step] {
+				order = append(order, step)
+			}
+		}
+		p.questLayers[layer] = questLayer{order: order, pass: 1, lastStep: localNow}
+	}
+	p.questing = true
+	p.questStartTime = questStartTime
+	// the interrupted run is continued instead of starting over or clearing its quests
+	p.questCheckLastHour = localNow.Hour()
+	p.questCheckLastMidnight = midnight.Unix()
+
+	log.Infof("[QUEST] %s: Resuming quest mode with %d pokestops checked, %d AR and %d non-AR steps remaining", p.Name,
+		restoredStops, len(p.questLayers[Quest_Layer_AR].order), len(p.questLayers[Quest_Layer_NoAr].order))
+}
@@ -0,0 +1,207 @@
+package worker
+
+import (
+	"time"
+
+	"flygon/geo"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+)
+
+// IsQuesting returns true while the area is walking its quest route
+func (p *WorkerArea) IsQuesting() bool {
+	p.questMutex.Lock()
+	defer p.questMutex.Unlock()
+	return p.questing
+}
+
+// questStepLookahead is the number of upcoming quest steps searched for one the worker can visit without cooldown
+const questStepLookahead = 10
+
+// questLayerIdleTimeout is the time a layer can go without a worker in its AR state, once the other layer
+// is finished the idle layer is given up so the area leaves quest mode
+const questLayerIdleTimeout = 30 * time.Minute
+
+// questLayer is the progress of one quest layer (AR / non-AR) over the quest route
+type questLayer struct {
+	step     int
+	order    []int // route steps of the current pass, nil for the whole route
+	pass     int
+	finished bool
+	lastStep time.Time // last time a step of the layer was handed out
+}
+
+func questLayerName(layer int) string {
+	if layer == Quest_Layer_AR {
+		return "ar"
+	}
+	return "no_ar"
+}
+
+// GetNextQuestStep hands out the next step of the quest layer of the worker's AR state. Every layer walks the
+// route on its own, as a pokestop only gives the AR quest to an account in AR state and the non-AR quest
+// otherwise, so steps are never handed out to a worker of the other layer. A layer which sees no worker for
+// questLayerIdleTimeout is given up once the other layer is finished. When both layers are finished the area
+// leaves quest mode and false is returned, so the worker can continue in pokemon mode
+func (p *WorkerArea) GetNextQuestStep(layer int, cooldown func(geo.Location) int64) (int, geo.Location, bool) {
+	p.questMutex.Lock()
+	defer p.questMutex.Unlock()
+
+	if !p.questing {
+		return 0, geo.Location{}, false
+	}
+
+	if step, location, ok := p.nextQuestLayerStep(layer, cooldown); ok {
+		return step, location, true
+	}
+
+	otherLayer := &p.questLayers[1-layer]
+	idleSince := otherLayer.lastStep
+	if idleSince.Before(p.questStartTime) {
+		idleSince = p.questStartTime
+	}
+	if !otherLayer.finished && time.Since(idleSince) > questLayerIdleTimeout {
+		log.Warnf("[QUEST] %s: No worker collected the %s layer for %s, giving it up after %d steps of pass %d", p.Name,
+			questLayerName(1-layer), time.Since(idleSince).Truncate(time.Second), otherLayer.step, otherLayer.pass)
+		otherLayer.finished = true
+	}
+
+	if p.questLayers[Quest_Layer_AR].finished && p.questLayers[Quest_Layer_NoAr].finished {
+		log.Infof("[QUEST] %s: Quest route finished after %s, returning to pokemon mode", p.Name, time.Since(p.questStartTime))
+		p.questing = false
+		go p.deleteQuestChecks()
+	}
+	return 0, geo.Location{}, false
+}
+
+// nextQuestLayerStep hands out the next step of the layer. If the next step would need a cooldown,
+// the upcoming step with the lowest cooldown is handed out instead. questMutex has to be locked
+func (p *WorkerArea) nextQuestLayerStep(layer int, cooldown func(geo.Location) int64) (int, geo.Location, bool) {
+	l := &p.questLayers[layer]
+	if l.finished {
+		return 0, geo.Location{}, false
+	}
+
+	if l.order == nil {
+		l.order = make([]int, len(p.questRoute))
+		for x := range l.order {
+			l.order[x] = x
+		}
+	}
+
+	if l.step >= len(l.order) {
+		rescanSteps := p.questRescanSteps(layer)
+		if len(rescanSteps) == 0 || l.pass >= maxQuestPasses {
+			log.Infof("[QUEST] %s: Quest layer %s finished after %s and %d passes", p.Name, questLayerName(layer), time.Since(p.questStartTime), l.pass)
+			l.finished = true
+			return 0, geo.Location{}, false
+		}
+		l.pass++
+		l.order = rescanSteps
+		l.step = 0
+		log.Infof("[QUEST] %s: Quests are missing (%d AR, %d non-AR of %d stops), starting %s re-scan pass %d with %d steps", p.Name,
+			p.questStatus.Quests, p.questStatus.AltQuests, p.questStatus.TotalStops, questLayerName(layer), l.pass, len(rescanSteps))
+	}
+
+	// the order of the remaining steps is swapped, so every step is still handed out exactly once
+	best := l.step
+	bestCooldown := cooldown(p.questRoute[l.order[best]])
+	for x := best + 1; bestCooldown > 0 && x < len(l.order) && x <= l.step+questStepLookahead; x++ {
+		if c := cooldown(p.questRoute[l.order[x]]); c < bestCooldown {
+			best = x
+			bestCooldown = c
+		}
+	}
+	l.order[l.step], l.order[best] = l.order[best], l.order[l.step]
+
+	step := l.order[l.step]
+	l.step++
+	l.lastStep = time.Now()
+	return step, p.questRoute[step], true
+}
+
+// StopQuesting leaves quest mode without finishing the route
+func (p *WorkerArea) StopQuesting() {
+	p.questMutex.Lock()
+	defer p.questMutex.Unlock()
+
+	if p.questing {
+		log.Infof("[QUEST] %s: Quest mode stopped at AR step %d, non-AR step %d of %d", p.Name,
+			p.questLayers[Quest_Layer_AR].step, p.questLayers[Quest_Layer_NoAr].step, len(p.questRoute))
+	}
+	p.questing = false
+}
+
+// RecordQuestScan stores which worker scanned the given quest layer of a pokestop
+func (p *WorkerArea) RecordQuestScan(fortId string, haveAr bool, workerUuid string, stepNo int, hasQuest bool) {
+	layer := Quest_Layer_NoAr
+	if haveAr {
+		layer = Quest_Layer_AR
+	}
+
+	p.questMutex.Lock()
+	pokestop := p.GetPokestopStatus(fortId)
+	pokestop.ScanData[layer] = PokestopScanInfo{
+		ScannedTime: time.Now(),
+		Worker:      workerUuid,
+		StepNo:      stepNo,
+	}
+	p.indexQuestStepStop(stepNo, fortId)
+	if layer == Quest_Layer_AR && hasQuest {
+		pokestop.HasArQuestReward = true
+	}
+	questCheck, ok := p.questCheckOfStep(stepNo)
+	p.questMutex.Unlock()
+
+	if ok {
+		p.saveQuestCheck(questCheck)
+	}
+}
+
+// checkQuestHours starts questing when a configured quest hour of the area's local time is reached
+func (p *WorkerArea) checkQuestHours(localNow time.Time) {
+	hour := localNow.Hour()
+	if !slices.Contains(p.questCheckHours, hour) {
+		p.questCheckLastHour = -1
+		return
+	}
+	if p.questCheckLastHour == hour {
+		return
+	}
+	p.questCheckLastHour = hour
+
+	log.Infof("[QUEST] %s: Quest hour %d reached, starting quest mode", p.Name, hour)
+	if !p.StartQuesting() {
+		log.Warnf("[QUEST] %s: Unable to start quest mode, quest route is empty", p.Name)
+	}
+}
+
+// checkQuestSchedule runs the daily quest cycle of all quest enabled areas in their local time:
+// the route is calculated before midnight, quests are cleared at midnight and questing starts at every quest hour.
+// The quest status is polled from Golbat while questing
+func checkQuestSchedule(now time.Time) {
+	for _, area := range GetWorkerAreas() {
+		if len(area.questCheckHours) == 0 {
+			continue
+		}
+		localNow := now.In(area.Timezone())
+		area.checkQuestRouteCalculation(localNow)
+		area.checkQuestStops(now)
+		area.checkQuestMidnight(localNow)
+		area.checkQuestHours(localNow)
+		if area.questStatusOutdated(now) {
+			area.updateQuestStatus()
+		}
+	}
+}
+
+func StartQuestCheckScheduler() {
+	ticker := time.NewTicker(time.Minute)
+	go func() {
+		checkQuestSchedule(time.Now())
+		for {
+			<-ticker.C
+			checkQuestSchedule(time.Now())
+		}
+	}()
+}
@@ -1,50 +1,170 @@
 package worker
 
 import (
+	"errors"
 	"time"
 
+	"flygon/config"
 	"flygon/db"
+	"flygon/geo"
+	"flygon/golbatapi"
 	"flygon/koji"
-	"flygon/tz"
 	log "github.com/sirupsen/logrus"
 )
 
-func QuestRouteBuilder() {
-	areas := GetWorkerAreas()
-
-	for _, area := range areas {
-		questFence := area.questFence
-		if len(questFence.Fence) > 0 {
-			boundingBox := questFence.GetBoundingBox()
-			centreLat := (boundingBox.MaximumLatitude + boundingBox.MinimumLatitude) / 2
-			centreLon := (boundingBox.MaximumLongitude + boundingBox.MinimumLongitude) / 2
-
-			stopTimezone := tz.GetTimezone(centreLat, centreLon)
-			if stopTimezone == nil {
-				log.Warnf("Failed to get timezone for area %s pos %f,%f", area.Name, centreLat, centreLon)
-				continue
-			}
-
-			currentHour := time.Now().In(stopTimezone).Hour()
-			if currentHour == 23 {
-				// Time to calculate!
-				log.Infof("Calculating quest route for area %s", area.Name)
-				calculateArea := area
-				go func() {
-					newRoute, err := koji.GetKojiRoute(calculateArea.questFence, calculateArea.Name)
-
-					if err == nil {
-						calculateArea.questRoute = newRoute
-						if err := db.UpdateAreaQuestRoute(naughtyDetails, calculateArea.Id, newRoute); err != nil {
-							log.Errorf("KOJI: %s: Failed to update area quest route: %s", calculateArea.Name, err)
-						}
-
-						calculateArea.AdjustQuestRoute(newRoute)
-					} else {
-						log.Errorf("KOJI: %s Koji Unable to calculate fast route - error %s", calculateArea.Name, err)
-					}
-				}()
-			}
+// questRouteCalculationHour is the local hour in which the quest route is calculated for the next day
+const questRouteCalculationHour = 23
+
+// questStopRadius is the distance in meters a worker can spin pokestops from, the same radius Koji uses
+const questStopRadius = 78.0
+
+// questStopCheckInterval is the interval in which Golbat is asked for new pokestops in the quest fence
+const questStopCheckInterval = time.Hour
+
+var errNoQuestRouteSource = errors.New("neither koji nor golbat api is configured")
+
+// buildQuestRoute calculates the quest route with Koji if it is configured, otherwise from the pokestops
+// Golbat knows inside the quest fence
+func (p *WorkerArea) buildQuestRoute() ([]geo.Location, error) {
+	if config.Config.Koji.Url != "" {
+		return koji.GetKojiRoute(p.questFence, p.Name)
+	}
+	if golbatapi.IsConfigured() {
+		return p.buildGolbatQuestRoute()
+	}
+	return nil, errNoQuestRouteSource
+}
+
+// buildGolbatQuestRoute builds a quest route with one step per pokestop, pokestops within spin range
+// of each other share a step
+func (p *WorkerArea) buildGolbatQuestRoute() ([]geo.Location, error) {
+	start := time.Now()
+	positions, err := golbatapi.GetPokestopPositions(p.questFence)
+	if err != nil {
+		return nil, err
+	}
+
+	stopIds := make(map[string]bool, len(positions))
+	locations := make([]geo.Location, 0, len(positions))
+	for _, position := range positions {
+		stopIds[position.Id] = true
+		locations = append(locations, geo.Location{Latitude: position.Latitude, Longitude: position.Longitude})
+	}
+
+	route := geo.OptimizeRoute(geo.ClusterLocations(locations, questStopRadius))
+	p.questMutex.Lock()
+	p.questStopIds = stopIds
+	p.questStopCheckTime = time.Now()
+	p.questMutex.Unlock()
+	log.Infof("[QUEST] %s: Built quest route with %d steps for %d pokestops from Golbat in %s", p.Name, len(route), len(positions), time.Since(start))
+
+	return route, nil
+}
+
+// updateQuestRoute stores a new quest route in the database and uses it
+func (p *WorkerArea) updateQuestRoute(route []geo.Location) {
+	if err := db.UpdateAreaQuestRoute(naughtyDetails, p.Id, route); err != nil {
+		log.Errorf("[QUEST] %s: Failed to update area quest route: %s", p.Name, err)
+	}
+	p.AdjustQuestRoute(route)
+}
+
+// checkQuestRouteCalculation recalculates the quest route once a day before local midnight
+func (p *WorkerArea) checkQuestRouteCalculation(localNow time.Time) {
+	if localNow.Hour() != questRouteCalculationHour || len(p.questFence.Fence) == 0 {
+		return
+	}
+	if config.Config.Koji.Url == "" && !golbatapi.IsConfigured() {
+		return
+	}
+	if !p.routeCalcMutex.TryLock() {
+		// calculation still running
+		return
+	}
+	if y, m, d := p.routeCalcTime.In(localNow.Location()).Date(); y == localNow.Year() && m == localNow.Month() && d == localNow.Day() {
+		p.routeCalcMutex.Unlock()
+		return
+	}
+	p.routeCalcTime = localNow
+
+	log.Infof("[QUEST] %s: Calculating quest route for the next day", p.Name)
+	go func() {
+		defer p.routeCalcMutex.Unlock()
+
+		newRoute, err := p.buildQuestRoute()
+		if err != nil {
+			log.Errorf("[QUEST] %s: Unable to calculate quest route - error %s", p.Name, err)
+			return
+		}
+		p.updateQuestRoute(newRoute)
+	}()
+}
+
+// checkQuestStops rebuilds a quest route built from Golbat pokestops when new pokestops appeared in the quest fence
+func (p *WorkerArea) checkQuestStops(now time.Time) {
+	if config.Config.Koji.Url != "" || !golbatapi.IsConfigured() || len(p.questFence.Fence) == 0 {
+		return
+	}
+	p.questMutex.Lock()
+	checkDue := now.Sub(p.questStopCheckTime) >= questStopCheckInterval && !p.questing
+	p.questMutex.Unlock()
+	if !checkDue {
+		return
+	}
+	if !p.routeCalcMutex.TryLock() {
+		return
+	}
+	defer p.routeCalcMutex.Unlock()
+
+	p.questMutex.Lock()
+	p.questStopCheckTime = now
+	p.questMutex.Unlock()
+	positions, err := golbatapi.GetPokestopPositions(p.questFence)
+	if err != nil {
+		log.Warnf("[QUEST] %s: Unable to get pokestops: %s", p.Name, err)
+		return
+	}
+
+	p.questMutex.Lock()
+	newStops := 0
+	for _, position := range positions {
+		if !p.questStopIds[position.Id] {
+			newStops++
 		}
 	}
+	routeLength := len(p.questRoute)
+	p.questMutex.Unlock()
+	if newStops == 0 && routeLength > 0 {
+		return
+	}
+
+	log.Infof("[QUEST] %s: %d new pokestops found, rebuilding quest route", p.Name, newStops)
+	newRoute, err := p.buildGolbatQuestRoute()
+	if err != nil {
+		log.Errorf("[QUEST] %s: Unable to calculate quest route - error %s", p.Name, err)
+		return
+	}
+	p.updateQuestRoute(newRoute)
+}
+
+// checkQuestMidnight clears the quests of the area in Golbat once the local day changed, as quests
+// are reset at local midnight
+func (p *WorkerArea) checkQuestMidnight(localNow time.Time) {
+	midnight := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, localNow.Location())
+	if p.questCheckLastMidnight == midnight.Unix() {
+		return
+	}
+	p.questCheckLastMidnight = midnight.Unix()
+
+	// after a start later in the day the quests of today are still valid
+	if localNow.Sub(midnight) > time.Hour || len(p.questFence.Fence) == 0 {
+		return
+	}
+
+	log.Infof("[QUEST] %s: Local midnight passed, clearing quests", p.Name)
+	p.StopQuesting()
+	if err := golbatapi.ClearQuests(p.questFence); err != nil {
+		log.Errorf("[QUEST] %s: Unable to clear quests: %s", p.Name, err)
+	}
+	p.clearQuestCache()
 }
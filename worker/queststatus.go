@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"sort"
+	"time"
+
+	"flygon/external"
+	"flygon/golbatapi"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxQuestPasses limits the passes over the quest route, including the re-scan passes
+const maxQuestPasses = 3
+
+// questStatusInterval is the interval in which the quest status is fetched outside quest mode
+const questStatusInterval = 15 * time.Minute
+
+type QuestLayerProgress struct {
+	Pass     int
+	Step     int
+	Steps    int
+	Finished bool
+}
+
+type QuestProgress struct {
+	Questing   bool
+	Layers     [2]QuestLayerProgress // indexed by Quest_Layer_AR / Quest_Layer_NoAr
+	Status     golbatapi.QuestStatus
+	StatusTime time.Time
+}
+
+// QuestProgress returns the state of the current quest run and the last quest status reported by Golbat
+func (p *WorkerArea) QuestProgress() QuestProgress {
+	p.questMutex.Lock()
+	defer p.questMutex.Unlock()
+
+	progress := QuestProgress{
+		Questing:   p.questing,
+		Status:     p.questStatus,
+		StatusTime: p.questStatusTime,
+	}
+	for layer, l := range p.questLayers {
+		steps := len(p.questRoute)
+		if l.order != nil {
+			steps = len(l.order)
+		}
+		progress.Layers[layer] = QuestLayerProgress{
+			Pass:     l.pass,
+			Step:     l.step,
+			Steps:    steps,
+			Finished: l.finished,
+		}
+	}
+	return progress
+}
+
+// updateQuestStatus fetches the quest status of the quest fence from Golbat
+func (p *WorkerArea) updateQuestStatus() {
+	if len(p.questFence.Fence) == 0 {
+		return
+	}
+	status, err := golbatapi.GetQuestStatus(p.questFence.Fence)
+	if err != nil {
+		log.Warnf("[QUEST] %s: Unable to get quest status: %s", p.Name, err)
+		return
+	}
+
+	p.questMutex.Lock()
+	p.questStatus = status
+	p.questStatusTime = time.Now()
+	p.questMutex.Unlock()
+
+	external.QuestStatus.WithLabelValues(p.Name, "quests").Set(float64(status.Quests))
+	external.QuestStatus.WithLabelValues(p.Name, "alt_quests").Set(float64(status.AltQuests))
+	external.QuestStatus.WithLabelValues(p.Name, "total").Set(float64(status.TotalStops))
+	log.Debugf("[QUEST] %s: Quest status %d AR, %d non-AR of %d stops", p.Name, status.Quests, status.AltQuests, status.TotalStops)
+}
+
+func (p *WorkerArea) questStatusOutdated(now time.Time) bool {
+	p.questMutex.Lock()
+	defer p.questMutex.Unlock()
+	return p.questing || now.Sub(p.questStatusTime) > questStatusInterval
+}
+
+// questRescanSteps returns the quest steps which have to be visited again for a layer because Golbat is
+// missing quests of that layer. These are steps without any recorded scan of the layer and steps with
+// a pokestop where only the other layer was captured. questMutex has to be locked
+func (p *WorkerArea) questRescanSteps(layer int) []int {
+	status := p.questStatus
+	if p.questStatusTime.IsZero() {
+		return nil
+	}
+	if (layer == Quest_Layer_AR && status.Quests >= status.TotalStops) ||
+		(layer == Quest_Layer_NoAr && status.AltQuests >= status.TotalStops) {
+		return nil
+	}
+
+	scannedSteps := make(map[int]bool)
+	rescan := make(map[int]bool)
+	for _, item := range p.pokestopCache.Items() {
+		pokestop := item.Value()
+		layerScan := pokestop.ScanData[layer]
+		otherScan := pokestop.ScanData[1-layer]
+		if !layerScan.ScannedTime.IsZero() {
+			scannedSteps[layerScan.StepNo] = true
+		} else if !otherScan.ScannedTime.IsZero() {
+			rescan[otherScan.StepNo] = true
+		}
+	}
+	for step := range p.questRoute {
+		if !scannedSteps[step] {
+			rescan[step] = true
+		}
+	}
+
+	steps := make([]int, 0, len(rescan))
+	for step := range rescan {
+		if step < len(p.questRoute) {
+			steps = append(steps, step)
+		}
+	}
+	sort.Ints(steps)
+	return steps
+}
@@ -0,0 +1,149 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"flygon/db"
+	"flygon/util"
+	log "github.com/sirupsen/logrus"
+)
+
+type areaSchedule struct {
+	db.AreaSchedule
+	cron *util.CronSchedule
+}
+
+var areaSchedules map[int][]areaSchedule
+var areaSchedulesMutex sync.RWMutex
+
+// LoadAreaSchedules reads all area schedules, invalid cron expressions are skipped
+func LoadAreaSchedules(dbDetails db.DbDetails) {
+	records, err := db.GetAreaScheduleRecords(dbDetails)
+	if err != nil {
+		log.Errorf("[SCHEDULE] Unable to load area schedules: %s", err)
+		return
+	}
+
+	schedules := make(map[int][]areaSchedule)
+	for _, record := range records {
+		cron, err := util.ParseCron(record.Cron)
+		if err != nil {
+			log.Errorf("[SCHEDULE] Schedule %d of area %d has invalid cron '%s': %s", record.Id, record.AreaId, record.Cron, err)
+			continue
+		}
+		schedules[record.AreaId] = append(schedules[record.AreaId], areaSchedule{
+			AreaSchedule: record,
+			cron:         cron,
+		})
+	}
+
+	areaSchedulesMutex.Lock()
+	areaSchedules = schedules
+	areaSchedulesMutex.Unlock()
+}
+
+func (p *WorkerArea) hasSchedules() bool {
+	areaSchedulesMutex.RLock()
+	defer areaSchedulesMutex.RUnlock()
+	return len(areaSchedules[p.Id]) > 0
+}
+
+// activeSchedule returns the schedule which started last, evaluated in the timezone of the area
+func (p *WorkerArea) activeSchedule(now time.Time) *areaSchedule {
+	areaSchedulesMutex.RLock()
+	defer areaSchedulesMutex.RUnlock()
+
+	schedules := areaSchedules[p.Id]
+	if len(schedules) == 0 {
+		return nil
+	}
+
+	localNow := now.In(p.Timezone())
+	var active *areaSchedule
+	var activeStart time.Time
+	for x := range schedules {
+		schedule := &schedules[x]
+		if start, ok := schedule.cron.Previous(localNow); ok && (active == nil || start.After(activeStart)) {
+			active = schedule
+			activeStart = start
+		}
+	}
+	return active
+}
+
+// checkSchedule applies the active schedule once its window starts, returns true if a schedule was applied
+func (p *WorkerArea) checkSchedule(now time.Time) bool {
+	schedule := p.activeSchedule(now)
+	if schedule == nil {
+		p.activeScheduleId = 0
+		return false
+	}
+	if schedule.Id == p.activeScheduleId {
+		return false
+	}
+	p.activeScheduleId = schedule.Id
+
+	log.Infof("[SCHEDULE] %s: Schedule %d (%s) started, pokemon workers %d, fort workers %d, quest mode %t",
+		p.Name, schedule.Id, schedule.Cron, schedule.PokemonModeWorkers, schedule.FortModeWorkers, schedule.QuestMode)
+
+	p.AdjustWorkers(schedule.PokemonModeWorkers)
+	p.AdjustFortWorkers(schedule.FortModeWorkers)
+	if schedule.QuestMode {
+		if !p.IsQuesting() && !p.startScheduledQuesting() {
+			log.Warnf("[SCHEDULE] %s: Unable to start quest mode, quest route is empty", p.Name)
+		}
+	} else {
+		p.stopScheduledQuesting()
+	}
+	p.RecalculateRouteParts()
+
+	return true
+}
+
+// startScheduledQuesting starts quest mode and remembers the run, so a later schedule only stops quest runs of schedules
+func (p *WorkerArea) startScheduledQuesting() bool {
+	if !p.StartQuesting() {
+		return false
+	}
+	p.questMutex.Lock()
+	p.scheduleQuestStart = p.questStartTime
+	p.questMutex.Unlock()
+	return true
+}
+
+// stopScheduledQuesting stops quest mode if the running quest run was started by a schedule, runs started by the
+// quest hours are left alone
+func (p *WorkerArea) stopScheduledQuesting() {
+	p.questMutex.Lock()
+	scheduled := p.questing && p.questStartTime.Equal(p.scheduleQuestStart)
+	p.questMutex.Unlock()
+	if scheduled {
+		p.StopQuesting()
+	}
+}
+
+func checkAreaSchedules() {
+	now := time.Now()
+	applied := false
+	for _, area := range GetWorkerAreas() {
+		if area.checkSchedule(now) {
+			applied = true
+		}
+	}
+	if applied {
+		AllocateIdleWorkers()
+	}
+}
+
+func StartAreaScheduler() {
+	checkAreaSchedules()
+
+	ticker := time.NewTicker(time.Minute)
+	go func() {
+		for {
+			<-ticker.C
+			checkAreaSchedules()
+		}
+	}()
+}
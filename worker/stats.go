@@ -0,0 +1,133 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"flygon/config"
+	"flygon/db"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v4"
+)
+
+// statCounters are the counters of one stats_workers row
+type statCounters struct {
+	locCount   int // locations handed out to the worker
+	locSuccess int // map objects received
+	monsSeen   int
+	monsEnc    int
+	stops      int
+	quests     int
+}
+
+func (c statCounters) isEmpty() bool {
+	return c.locCount == 0 && c.locSuccess == 0 && c.monsEnc == 0 && c.stops == 0
+}
+
+// WorkerStats counts the work of a worker since the last flush into stats_workers
+type WorkerStats struct {
+	counters statCounters
+	since    time.Time
+	mutex    sync.Mutex
+}
+
+func newWorkerStats() *WorkerStats {
+	return &WorkerStats{since: time.Now()}
+}
+
+func (s *WorkerStats) RecordLocation() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.counters.locCount++
+}
+
+func (s *WorkerStats) RecordMapObjects(monsSeen int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.counters.locSuccess++
+	s.counters.monsSeen += monsSeen
+}
+
+func (s *WorkerStats) RecordEncounter() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.counters.monsEnc++
+}
+
+func (s *WorkerStats) RecordFortSearch(hasQuest bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.counters.stops++
+	if hasQuest {
+		s.counters.quests++
+	}
+}
+
+// flush returns the counters and the seconds they were collected in, and starts counting again
+func (s *WorkerStats) flush(now time.Time) (statCounters, float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	counters := s.counters
+	elapsed := now.Sub(s.since).Seconds()
+	s.counters = statCounters{}
+	s.since = now
+	return counters, elapsed
+}
+
+// SaveWorkerStats writes the statistics of all workers which did some work since the last flush
+func SaveWorkerStats(dbDetails db.DbDetails) {
+	now := time.Now()
+	var records []db.WorkerStats
+	for _, ws := range GetWorkers() {
+		counters, elapsed := ws.Stats().flush(now)
+		if counters.isEmpty() {
+			continue
+		}
+
+		// average seconds a worker spent per location
+		locAvg := null.Float{}
+		if counters.locCount > 0 {
+			locAvg = null.FloatFrom(elapsed / float64(counters.locCount))
+		}
+
+		// the uuid is the only identifier a device sends, so it is the device name as well
+		records = append(records, db.WorkerStats{
+			Datetime:         now.Unix(),
+			ControllerWorker: ws.Uuid,
+			DeviceName:       null.StringFrom(ws.Uuid),
+			LocAvg:           locAvg,
+			LocCount:         null.IntFrom(int64(counters.locCount)),
+			LocSuccess:       null.IntFrom(int64(counters.locSuccess)),
+			MonsSeen:         null.IntFrom(int64(counters.monsSeen)),
+			MonsEnc:          null.IntFrom(int64(counters.monsEnc)),
+			Stops:            null.IntFrom(int64(counters.stops)),
+			Quests:           null.IntFrom(int64(counters.quests)),
+		})
+	}
+
+	if err := db.InsertWorkerStats(dbDetails, records); err != nil {
+		log.Errorf("[WORKER] Unable to save worker stats: %s", err)
+		return
+	}
+	log.Debugf("[WORKER] Saved stats of %d workers", len(records))
+}
+
+// WorkerStatsEnabled returns true if worker statistics are written into stats_workers
+func WorkerStatsEnabled() bool {
+	return config.Config.General.WorkerStats && config.Config.General.WorkerStatsInterval > 0
+}
+
+// StartWorkerStatsWriter flushes the worker statistics every general.worker_stats_interval minutes
+func StartWorkerStatsWriter(dbDetails db.DbDetails) {
+	if !WorkerStatsEnabled() {
+		return
+	}
+	interval := config.Config.General.WorkerStatsInterval
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	go func() {
+		for {
+			<-ticker.C
+			SaveWorkerStats(dbDetails)
+		}
+	}()
+}
@@ -4,19 +4,52 @@ import (
 	"sort"
 	"sync"
 	"time"
+
+	"flygon/config"
+	"flygon/db"
+	"flygon/geo"
+	log "github.com/sirupsen/logrus"
+)
+
+type Mode int
+
+const (
+	PokemonMode Mode = iota
+	FortMode
+	LevelingMode
 )
 
+func (m Mode) String() string {
+	switch m {
+	case PokemonMode:
+		return "pokemon"
+	case FortMode:
+		return "fort"
+	case LevelingMode:
+		return "leveling"
+	}
+	return "unknown"
+}
+
 type State struct {
 	Uuid           string
 	AreaId         int
+	Mode           Mode
 	Username       string
 	StartStep      int
 	EndStep        int
 	Step           int
+	QuestStep      int
 	Host           string
 	LastSeen       int64
 	requestCounter *RequestCounter
+	stats          *WorkerStats
 	mu             sync.Mutex
+
+	haveAr                  *bool
+	lastLocation            geo.Location
+	lastInteractionLocation geo.Location
+	lastInteractionTime     int64
 }
 
 var requestLimits map[int]int
@@ -36,6 +69,7 @@ func GetWorkerState(workerId string) *State {
 			Uuid:           workerId,
 			LastSeen:       time.Now().Unix(),
 			requestCounter: NewRequestCounter(),
+			stats:          newWorkerStats(),
 		}
 		newState.SetRequestLimits(requestLimits)
 		states[workerId] = newState
@@ -45,15 +79,61 @@ func GetWorkerState(workerId string) *State {
 	}
 }
 
-func CleanWorkerState() {
+// CleanWorkerState removes all workers not seen within the expiry, releases their accounts and
+// recalculates the areas they were working in
+func CleanWorkerState(dbDetails db.DbDetails, expiry int64, releaseAccount func(username string)) {
+	now := time.Now().Unix()
+	var expired []*State
+
 	statesMutex.Lock()
-	defer statesMutex.Unlock()
+	for uuid, ws := range states {
+		if now-ws.LastSeen > expiry {
+			expired = append(expired, ws)
+			delete(states, uuid)
+		}
+	}
+	statesMutex.Unlock()
 
-	for k, v := range states {
-		// Detect if area out of date; remove from area
-		// Remove from worker
-		_, _ = k, v
+	if len(expired) == 0 {
+		return
 	}
+
+	changedAreas := make(map[int]*WorkerArea)
+	for _, ws := range expired {
+		log.Infof("[WORKER] [%s] Worker expired, last seen %s ago", ws.Uuid, time.Duration(now-ws.LastSeen)*time.Second)
+		if ws.Username != "" {
+			releaseAccount(ws.Username)
+		}
+		if area := GetWorkerArea(ws.AreaId); area != nil {
+			changedAreas[area.Id] = area
+		}
+		ws.ResetUsername()
+		ws.ResetAreaAndRoutePart()
+	}
+
+	for _, area := range changedAreas {
+		area.RecalculateRouteParts()
+	}
+
+	if err := db.DeleteWorkersNotSeenSince(dbDetails, now-expiry); err != nil {
+		log.Errorf("[WORKER] Unable to delete expired workers: %s", err)
+	}
+}
+
+// StartWorkerStateJanitor periodically expires workers which were not seen for the configured time
+func StartWorkerStateJanitor(dbDetails db.DbDetails, releaseAccount func(username string)) {
+	expiry := int64(config.Config.Worker.StateExpiry)
+	if expiry <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(5 * time.Minute)
+	go func() {
+		for {
+			<-ticker.C
+			CleanWorkerState(dbDetails, expiry, releaseAccount)
+		}
+	}()
 }
 
 func CountWorkersWithArea(areaId int) int {
@@ -70,6 +150,20 @@ func CountWorkersWithArea(areaId int) int {
 	return count
 }
 
+func CountWorkersWithAreaAndMode(areaId int, mode Mode) int {
+	statesMutex.Lock()
+	defer statesMutex.Unlock()
+
+	count := 0
+	for _, v := range states {
+		if v.AreaId == areaId && v.Mode == mode {
+			count++
+		}
+	}
+
+	return count
+}
+
 func GetWorkersWithArea(areaId int) []*State {
 	statesMutex.Lock()
 	defer statesMutex.Unlock()
@@ -118,6 +212,11 @@ func (ws *State) Unlock() {
 func (ws *State) SetUsername(username string) {
 	ws.Lock()
 	defer ws.Unlock()
+	if ws.Username != username {
+		// cooldown and AR state belong to the account
+		ws.resetCooldown()
+		ws.haveAr = nil
+	}
 	ws.Username = username
 }
 
@@ -125,12 +224,32 @@ func (ws *State) ResetUsername() {
 	ws.Lock()
 	defer ws.Unlock()
 	ws.Username = ""
+	ws.resetCooldown()
+	ws.haveAr = nil
+}
+
+// SetHaveAr stores whether the account of the worker is able to receive AR quests
+func (ws *State) SetHaveAr(haveAr bool) {
+	ws.Lock()
+	defer ws.Unlock()
+	ws.haveAr = &haveAr
+}
+
+// QuestLayer returns the quest layer the account of the worker collects, AR while the AR state is unknown
+func (ws *State) QuestLayer() int {
+	ws.Lock()
+	defer ws.Unlock()
+	if ws.haveAr != nil && !*ws.haveAr {
+		return Quest_Layer_NoAr
+	}
+	return Quest_Layer_AR
 }
 
 func (ws *State) ResetAreaAndRoutePart() {
 	ws.Lock()
 	defer ws.Unlock()
 	ws.AreaId = 0
+	ws.Mode = PokemonMode
 	ws.StartStep = 0
 	ws.EndStep = 0
 	ws.Step = 0
@@ -147,6 +266,9 @@ func (ws *State) LastLocation(lat, lon float64, host string) {
 	defer ws.Unlock()
 	ws.Host = host
 	ws.LastSeen = time.Now().Unix()
+	if lat != 0 || lon != 0 {
+		ws.lastLocation = geo.Location{Latitude: lat, Longitude: lon}
+	}
 }
 
 func (ws *State) SetRequestLimits(limits map[int]int) {
@@ -175,6 +297,11 @@ func (ws *State) RequestCounts() map[int]int {
 	return ws.requestCounter.RequestCounts()
 }
 
+// Stats returns the statistics collected for the worker since the last flush
+func (ws *State) Stats() *WorkerStats {
+	return ws.stats
+}
+
 func (ws *State) ResetCounter() {
 	ws.Lock()
 	defer ws.Unlock()
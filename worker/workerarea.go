@@ -3,9 +3,10 @@ package worker
 import (
 	"errors"
 	"flygon/config"
+	"flygon/db"
 	"flygon/geo"
 	"flygon/golbatapi"
-	"flygon/koji"
+	"flygon/tz"
 	"github.com/jellydator/ttlcache/v3"
 	log "github.com/sirupsen/logrus"
 	"math"
@@ -15,20 +16,51 @@ import (
 )
 
 type WorkerArea struct {
-	Id                int
-	Name              string
-	TargetWorkerCount int
-	route             []geo.Location
-	pokemonRoute      []geo.Location
+	Id                    int
+	Name                  string
+	TargetWorkerCount     int
+	FortTargetWorkerCount int
+	Leveling              bool
+	Priority              int
+	Weight                int
+	Spillover             bool
+	RoutePartition        string
+	AccountGroups         []string
+	route                 []geo.Location
+	pokemonRoute          []geo.Location
+	fortRoute             []geo.Location
 
 	questFence             geo.Geofence
 	questRoute             []geo.Location
 	questCheckLastHour     int
 	questCheckLastMidnight int64
 
+	questMutex      sync.Mutex
+	questing        bool
+	questLayers     [2]questLayer
+	questStepStops  map[int][]string // pokestops scanned from each quest step
+	questStartTime  time.Time
+	questStatus     golbatapi.QuestStatus
+	questStatusTime time.Time
+
+	questStopIds       map[string]bool // pokestops of a quest route built from Golbat, guarded by questMutex
+	questStopCheckTime time.Time
+
+	scheduleQuestStart time.Time // start of the quest run started by a schedule
+
 	routeCalcMutex sync.Mutex
 	routeCalcTime  time.Time
 
+	partitionMutex sync.RWMutex
+	routeOrder     map[Mode][]int
+	partitionCache map[Mode]routePartition
+
+	coverage routeCoverage
+	leases   jobLeases
+
+	timezone         *time.Location
+	activeScheduleId int
+
 	pokemonEncounterCache *ttlcache.Cache[encounterCacheKey, bool]
 	pokestopCache         *ttlcache.Cache[string, *PokestopQuestInfo]
 	questCheckHours       []int
@@ -41,14 +73,14 @@ type encounterCacheKey struct {
 }
 
 type PokestopQuestInfo struct {
-	ScanData         [2]PokestopScanInfo
-	HasArQuestReward bool
+	ScanData         [2]PokestopScanInfo `json:"scan_data"`
+	HasArQuestReward bool                `json:"has_ar_quest_reward"`
 }
 
 type PokestopScanInfo struct {
-	ScannedTime time.Time
-	Worker      string
-	StepNo      int
+	ScannedTime time.Time `json:"scanned_time"`
+	Worker      string    `json:"worker"`
+	StepNo      int       `json:"step_no"`
 }
 
 const Quest_Layer_AR = 0
@@ -79,15 +111,19 @@ func RegisterArea(area *WorkerArea) {
 
 func RemoveArea(area *WorkerArea) {
 	workerAccessMutex.Lock()
-	defer workerAccessMutex.Unlock()
 
-	for _, state := range GetWorkersWithArea(area.Id) {
+	displacedWorkers := GetWorkersWithArea(area.Id)
+	for _, state := range displacedWorkers {
 		state.ResetAreaAndRoutePart()
 	}
 	if workerAreas != nil {
 		delete(workerAreas, area.Id)
 	}
 
+	workerAccessMutex.Unlock()
+
+	// move displaced workers right away instead of waiting for their next init
+	reallocateWorkers(displacedWorkers)
 }
 
 func GetWorkerAreas() (results []*WorkerArea) {
@@ -108,17 +144,19 @@ func GetWorkerArea(areaId int) *WorkerArea {
 	return nil
 }
 
-func NewWorkerArea(id int, name string, workerCount int, route []geo.Location, questGeofence geo.Geofence, questRoute []geo.Location, questCheckHours []int) *WorkerArea {
+func NewWorkerArea(id int, name string, workerCount int, route []geo.Location, fortWorkerCount int, fortRoute []geo.Location, questGeofence geo.Geofence, questRoute []geo.Location, questCheckHours []int) *WorkerArea {
 	w := WorkerArea{
-		Id:                 id,
-		Name:               name,
-		TargetWorkerCount:  workerCount,
-		route:              route,
-		pokemonRoute:       route,
-		questFence:         questGeofence,
-		questRoute:         questRoute,
-		questCheckHours:    questCheckHours,
-		questCheckLastHour: -1,
+		Id:                    id,
+		Name:                  name,
+		TargetWorkerCount:     workerCount,
+		FortTargetWorkerCount: fortWorkerCount,
+		route:                 route,
+		pokemonRoute:          route,
+		fortRoute:             fortRoute,
+		questFence:            questGeofence,
+		questRoute:            questRoute,
+		questCheckHours:       questCheckHours,
+		questCheckLastHour:    -1,
 	}
 	w.startCache()
 
@@ -152,6 +190,10 @@ func (ws *State) DeAllocateArea() {
 }
 
 func (ws *State) AllocateArea() (*WorkerArea, error) {
+	// pinned workers always work their assigned area
+	if area, _ := ws.allocatePinnedArea(); area != nil {
+		return area, nil
+	}
 	// worker is already assigned to an area, use that
 	if ws.AreaId != 0 { // no area uses ID = 0, auto increment starts with 1
 		return workerAreas[ws.AreaId], nil
@@ -165,28 +207,99 @@ func (ws *State) AllocateArea() (*WorkerArea, error) {
 	// Set states
 	workerAccessMutex.Lock()
 	defer workerAccessMutex.Unlock()
-	// Find area with the least workers that needs workers
-	var leastWorkersArea *WorkerArea
-	leastWorkersInArea := 0
+	// Higher priority areas are filled first, within a priority pokemon mode is filled before fort and leveling mode
+	if area, mode := findAreaNeedingWorkers(allocationModes); area != nil {
+		ws.AreaId = area.Id
+		ws.Mode = mode
+		return area, nil
+	}
 
-	for _, a := range workerAreas {
-		totalWorkerInArea := CountWorkersWithArea(a.Id)
-		if totalWorkerInArea >= a.TargetWorkerCount {
-			continue
-		}
+	// All areas are satisfied, surplus workers overflow into spillover areas
+	if area, mode := findSpilloverArea(); area != nil {
+		ws.AreaId = area.Id
+		ws.Mode = mode
+		return area, nil
+	}
+
+	return nil, ErrNoAreaNeedsWorkers
+}
+
+// PromoteFromLeveling moves a worker whose account reached level 30 out of its leveling area into
+// a normal area needing workers. The worker stays in its leveling area if no other area needs workers
+func (ws *State) PromoteFromLeveling() bool {
+	if ws.Mode != LevelingMode {
+		return false
+	}
+
+	workerAccessMutex.Lock()
+	defer workerAccessMutex.Unlock()
+
+	area, mode := findAreaNeedingWorkers([]Mode{PokemonMode, FortMode})
+	if area == nil {
+		return false
+	}
 
-		if leastWorkersArea == nil || totalWorkerInArea < leastWorkersInArea {
-			leastWorkersArea = a
-			leastWorkersInArea = totalWorkerInArea
+	oldArea := workerAreas[ws.AreaId]
+	ws.ResetAreaAndRoutePart()
+	ws.AreaId = area.Id
+	ws.Mode = mode
+	if oldArea != nil {
+		oldArea.RecalculateRouteParts()
+	}
+	return true
+}
+
+var allocationModes = []Mode{PokemonMode, FortMode, LevelingMode}
+
+// targetWorkerCountForMode returns the wanted worker count of a mode, leveling areas only have leveling workers
+func (p *WorkerArea) targetWorkerCountForMode(mode Mode) int {
+	switch mode {
+	case FortMode:
+		if p.Leveling {
+			return 0
+		}
+		return p.FortTargetWorkerCount
+	case LevelingMode:
+		if !p.Leveling {
+			return 0
 		}
+		return p.TargetWorkerCount
+	default:
+		if p.Leveling {
+			return 0
+		}
+		return p.TargetWorkerCount
 	}
+}
 
-	if leastWorkersArea == nil {
-		return nil, ErrNoAreaNeedsWorkers
+// routeForMode returns the route walked by workers of a mode, leveling uses the pokestop based
+// quest route when available
+func (p *WorkerArea) routeForMode(mode Mode) []geo.Location {
+	switch mode {
+	case FortMode:
+		return p.fortRoute
+	case LevelingMode:
+		if len(p.questRoute) > 0 {
+			return p.questRoute
+		}
+		return p.pokemonRoute
+	default:
+		return p.pokemonRoute
 	}
+}
 
-	ws.AreaId = leastWorkersArea.Id
-	return leastWorkersArea, nil
+// AllocateIdleWorkers allocates active workers without an area to areas which need workers
+func AllocateIdleWorkers() {
+	now := time.Now().Unix()
+	for _, ws := range GetWorkersWithArea(0) {
+		if now-ws.LastSeen > workerUnseen {
+			continue
+		}
+		if area, err := ws.AllocateArea(); err == nil {
+			log.Infof("[WORKERAREA] [%s] Allocated idle worker to area %d:%s", ws.Uuid, area.Id, area.Name)
+			area.RecalculateRouteParts()
+		}
+	}
 }
 
 func (p *WorkerArea) RecalculateRouteParts() {
@@ -204,11 +317,33 @@ func (p *WorkerArea) RecalculateRouteParts() {
 		}
 	}
 
+	if len(activeWorkers) == 0 {
+		log.Warnf("[WORKERAREA] No active workers to recalculate area")
+		return
+	}
+
+	// Every mode has its own worker pool walking its own route
+	for _, mode := range allocationModes {
+		var modeWorkers []*State
+		for _, ws := range activeWorkers {
+			if ws.Mode == mode {
+				modeWorkers = append(modeWorkers, ws)
+			}
+		}
+		if p.RoutePartition == db.RoutePartitionCluster {
+			p.recalculateClusterRouteParts(mode, modeWorkers)
+		} else {
+			p.setRouteOrder(mode, nil)
+			p.recalculateModeRouteParts(p.routeForMode(mode), modeWorkers)
+		}
+	}
+}
+
+func (p *WorkerArea) recalculateModeRouteParts(route []geo.Location, activeWorkers []*State) {
 	// Calculate route parts
-	numSteps := len(p.pokemonRoute)
+	numSteps := len(route)
 	numWorkers := len(activeWorkers)
 	if numWorkers == 0 {
-		log.Warnf("[WORKERAREA] No active workers to recalculate area")
 		return
 	}
 	stepsPerWorker := numSteps / numWorkers
@@ -245,6 +380,10 @@ func StartWorkerRoutePartRecalculationScheduler() {
 			<-ticker.C
 			log.Infof("[WORKERAREA] Recalculate Route Parts If Needed")
 			RecalculateRoutePartsIfNeeded()
+			PreemptWorkers()
+			for _, area := range GetWorkerAreas() {
+				area.ExpireLeases()
+			}
 		}
 	}()
 }
@@ -265,9 +404,41 @@ func RecalculateRoutePartsIfNeeded() {
 	}
 }
 
-func (p *WorkerArea) GetRouteLocationOfStep(stepNo int) geo.Location {
-	//TODO safe check, check for length before?
-	return p.route[stepNo]
+func (p *WorkerArea) GetRouteLocationOfStep(mode Mode, stepNo int) (geo.Location, bool) {
+	route := p.routeForMode(mode)
+	index := p.RouteIndexOfStep(mode, stepNo)
+	if index < 0 || index >= len(route) {
+		return geo.Location{}, false
+	}
+	return route[index], true
+}
+
+// Timezone returns the timezone of the area's geofence centre, falling back to the local timezone
+func (p *WorkerArea) Timezone() *time.Location {
+	if p.timezone != nil {
+		return p.timezone
+	}
+
+	var centre geo.Location
+	if len(p.questFence.Fence) > 0 {
+		boundingBox := p.questFence.GetBoundingBox()
+		centre = geo.Location{
+			Latitude:  (boundingBox.MaximumLatitude + boundingBox.MinimumLatitude) / 2,
+			Longitude: (boundingBox.MaximumLongitude + boundingBox.MinimumLongitude) / 2,
+		}
+	} else if len(p.pokemonRoute) > 0 {
+		centre = p.pokemonRoute[0]
+	} else {
+		return time.Local
+	}
+
+	areaTimezone := tz.GetTimezone(centre.Latitude, centre.Longitude)
+	if areaTimezone == nil {
+		log.Warnf("Failed to get timezone for area %s pos %f,%f", p.Name, centre.Latitude, centre.Longitude)
+		return time.Local
+	}
+	p.timezone = areaTimezone
+	return p.timezone
 }
 
 // GetPokestopStatus Returns a cell object for given cell id, creates a new one if not seen before
@@ -299,9 +470,13 @@ func (p *WorkerArea) startCache() {
 	}
 }
 
-// clearQuestCache clears the pokestop cache so new questing can begin
+// clearQuestCache clears the pokestop cache and the stored quest checks so new questing can begin
 func (p *WorkerArea) clearQuestCache() {
+	p.questMutex.Lock()
+	p.questStepStops = nil
+	p.questMutex.Unlock()
 	p.pokestopCache.DeleteAll()
+	p.deleteQuestChecks()
 }
 
 func (p *WorkerArea) StartQuesting() bool {
@@ -314,11 +489,18 @@ func (p *WorkerArea) StartQuesting() bool {
 
 	p.clearQuestCache()
 
-	if len(p.questRoute) > 0 {
-		return true
+	if len(p.questRoute) == 0 {
+		return false
 	}
 
-	return false
+	p.questMutex.Lock()
+	defer p.questMutex.Unlock()
+	p.questing = true
+	p.questLayers = [2]questLayer{{pass: 1}, {pass: 1}}
+	p.questStartTime = time.Now()
+	log.Infof("[QUEST] %s: Starting quest mode with %d steps", p.Name, len(p.questRoute))
+
+	return true
 }
 
 func (p *WorkerArea) RouteLength() int {
@@ -326,34 +508,53 @@ func (p *WorkerArea) RouteLength() int {
 }
 
 func (p *WorkerArea) calculateQuestRoute() {
-	// This shouldn't be done like this, but hacking it into place right now
-	if config.Config.Koji.Url != "" {
-		p.calculateKojiQuestRoute()
+	if config.Config.Koji.Url == "" && !golbatapi.IsConfigured() {
+		log.Infof("[QUEST] %s: quest route is empty and neither koji nor golbat api is configured, no routes will be calculated", p.Name)
+		return
 	}
-	log.Infof("KOJI: quest route is empty and koji url is empty, no routes will be calculated")
-
-}
-
-func (p *WorkerArea) calculateKojiQuestRoute() {
-	log.Infof("KOJI: %s Calculating shortest quest route using Koji Web Service", p.Name)
+	log.Infof("[QUEST] %s: Calculating quest route", p.Name)
 	start := time.Now()
-	shortRoute, err := koji.GetKojiRoute(p.questFence, p.Name)
-	log.Infof("KOJI: %s Koji routecalc took %s", p.Name, time.Since(start))
+	p.routeCalcMutex.Lock()
+	shortRoute, err := p.buildQuestRoute()
+	p.routeCalcMutex.Unlock()
+	log.Infof("[QUEST] %s: Quest routecalc took %s", p.Name, time.Since(start))
 
-	if err == nil {
-		p.questRoute = shortRoute
+	if err != nil {
+		log.Errorf("Unable to calculate fast route - error %s", err)
+		return
 	}
-	log.Errorf("Unable to calculate fast route - error %s", err)
+	p.updateQuestRoute(shortRoute)
 }
 
 // AdjustRoute allows a hot reload of the route
 func (p *WorkerArea) AdjustRoute(newRoute []geo.Location) {
 	p.route = newRoute
+	p.pokemonRoute = newRoute
+	p.resetPartitionCache()
+	p.RecalculateRouteParts()
+}
+
+// AdjustRoutePartition switches the partition strategy, workers get new route parts right away
+func (p *WorkerArea) AdjustRoutePartition(partition string) {
+	p.RoutePartition = partition
+	for _, ws := range GetWorkersWithArea(p.Id) {
+		ws.StartStep = 0
+		ws.EndStep = 0
+		ws.Step = 0
+	}
+	p.RecalculateRouteParts()
+}
+
+// AdjustFortRoute allows a hot reload of the fort route
+func (p *WorkerArea) AdjustFortRoute(newRoute []geo.Location) {
+	p.fortRoute = newRoute
+	p.resetPartitionCache()
 	p.RecalculateRouteParts()
 }
 
 func (p *WorkerArea) AdjustQuestFence(newQuestFence geo.Geofence) {
 	p.questFence = newQuestFence
+	p.timezone = nil
 }
 
 // AdjustWorkers allows a hot recalculation of worker numbers
@@ -361,15 +562,60 @@ func (p *WorkerArea) AdjustWorkers(newWorkers int) {
 	if p.TargetWorkerCount == newWorkers {
 		return
 	}
+	oldWorkers := p.TargetWorkerCount
 	p.TargetWorkerCount = newWorkers
-	if newWorkers <= p.TargetWorkerCount {
+	if newWorkers < oldWorkers {
 		log.Debugf("[WORKERAREA] Worker amount was reduced we have to recalculate")
-		//TODO if we reduce worker amount, we need to recalculate route parts and remove worker
+		if p.Leveling {
+			p.evictSurplusWorkers(LevelingMode)
+		} else {
+			p.evictSurplusWorkers(PokemonMode)
+		}
+	} else {
+		AllocateIdleWorkers()
+	}
+}
+
+// AdjustLeveling switches the area between leveling and normal mode, current workers have to be allocated again
+func (p *WorkerArea) AdjustLeveling(leveling bool) {
+	if p.Leveling == leveling {
+		return
+	}
+	p.Leveling = leveling
+	workers := GetWorkersWithArea(p.Id)
+	for _, ws := range workers {
+		ws.ResetAreaAndRoutePart()
+	}
+	reallocateWorkers(workers)
+}
+
+// AdjustFortWorkers allows a hot recalculation of fort mode worker numbers
+func (p *WorkerArea) AdjustFortWorkers(newWorkers int) {
+	if p.FortTargetWorkerCount == newWorkers {
+		return
+	}
+	oldWorkers := p.FortTargetWorkerCount
+	p.FortTargetWorkerCount = newWorkers
+	if newWorkers < oldWorkers {
+		p.evictSurplusWorkers(FortMode)
+	} else {
+		AllocateIdleWorkers()
 	}
 }
 
 func (p *WorkerArea) AdjustQuestRoute(route []geo.Location) {
+	p.questMutex.Lock()
+	defer p.questMutex.Unlock()
 	p.questRoute = route
+	p.questStepStops = nil
+	p.resetPartitionCache()
+	for layer := range p.questLayers {
+		l := &p.questLayers[layer]
+		l.order = nil
+		if l.step > len(route) {
+			l.step = len(route)
+		}
+	}
 }
 
 func (p *WorkerArea) AdjustQuestCheckHours(hours []int) {